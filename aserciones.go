@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Bytes del cuerpo que se leen para evaluar aserciones
+const maxCuerpoAsercion = 64 * 1024
+
+// Condicion sobre la respuesta de -assert-url obtenida a traves del proxy, por ejemplo
+// status == 204, body contains "ok" o header Server =~ "cloudflare"
+type Asercion struct {
+	Texto    string
+	Campo    string // "status", "body" o "header"
+	Cabecera string // nombre de la cabecera cuando Campo es "header"
+	Operador string // ==, !=, <, <=, >, >=, contains, !contains, =~, !~
+	Valor    string
+	numero   int
+	regex    *regexp.Regexp
+}
+
+// Separa una asercion en palabras respetando los valores entre comillas
+func separarAsercion(texto string) ([]string, error) {
+	var palabras []string
+	resto := strings.TrimSpace(texto)
+	for resto != "" {
+		if resto[0] == '"' {
+			valor, err := strconv.QuotedPrefix(resto)
+			if err != nil {
+				return nil, fmt.Errorf("comillas sin cerrar")
+			}
+			palabras = append(palabras, valor)
+			resto = strings.TrimSpace(resto[len(valor):])
+			continue
+		}
+		fin := strings.IndexFunc(resto, unicode.IsSpace)
+		if fin < 0 {
+			fin = len(resto)
+		}
+		palabras = append(palabras, resto[:fin])
+		resto = strings.TrimSpace(resto[fin:])
+	}
+	return palabras, nil
+}
+
+func ParsearAsercion(texto string) (Asercion, error) {
+	asercion := Asercion{Texto: texto}
+	palabras, err := separarAsercion(texto)
+	if err != nil {
+		return asercion, fmt.Errorf("asercion %q: %v", texto, err)
+	}
+	if len(palabras) > 0 {
+		asercion.Campo = strings.ToLower(palabras[0])
+		if asercion.Campo == "header" && len(palabras) > 1 {
+			asercion.Cabecera = palabras[1]
+			palabras = palabras[1:]
+		}
+	}
+	if len(palabras) != 3 {
+		return asercion, fmt.Errorf("asercion %q: se esperaba <campo> <operador> <valor>", texto)
+	}
+	asercion.Operador = palabras[1]
+	asercion.Valor = palabras[2]
+	if strings.HasPrefix(asercion.Valor, "\"") {
+		if asercion.Valor, err = strconv.Unquote(asercion.Valor); err != nil {
+			return asercion, fmt.Errorf("asercion %q: %v", texto, err)
+		}
+	}
+
+	switch asercion.Campo {
+	case "status":
+		switch asercion.Operador {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return asercion, fmt.Errorf("asercion %q: operador %s no valido para status", texto, asercion.Operador)
+		}
+		if asercion.numero, err = strconv.Atoi(asercion.Valor); err != nil {
+			return asercion, fmt.Errorf("asercion %q: status debe ser un numero", texto)
+		}
+	case "body", "header":
+		switch asercion.Operador {
+		case "==", "!=", "contains", "!contains":
+		case "=~", "!~":
+			if asercion.regex, err = regexp.Compile(asercion.Valor); err != nil {
+				return asercion, fmt.Errorf("asercion %q: %v", texto, err)
+			}
+		default:
+			return asercion, fmt.Errorf("asercion %q: operador %s no valido para %s", texto, asercion.Operador, asercion.Campo)
+		}
+	default:
+		return asercion, fmt.Errorf("asercion %q: campo desconocido %q, se esperaba status, body o header", texto, asercion.Campo)
+	}
+	return asercion, nil
+}
+
+// Indica si la respuesta cumple la asercion
+func (a Asercion) Evaluar(resp *http.Response, cuerpo []byte) bool {
+	if a.Campo == "status" {
+		switch a.Operador {
+		case "==":
+			return resp.StatusCode == a.numero
+		case "!=":
+			return resp.StatusCode != a.numero
+		case "<":
+			return resp.StatusCode < a.numero
+		case "<=":
+			return resp.StatusCode <= a.numero
+		case ">":
+			return resp.StatusCode > a.numero
+		default:
+			return resp.StatusCode >= a.numero
+		}
+	}
+
+	valor := string(cuerpo)
+	if a.Campo == "header" {
+		valor = resp.Header.Get(a.Cabecera)
+	}
+	switch a.Operador {
+	case "==":
+		return valor == a.Valor
+	case "!=":
+		return valor != a.Valor
+	case "contains":
+		return strings.Contains(valor, a.Valor)
+	case "!contains":
+		return !strings.Contains(valor, a.Valor)
+	case "=~":
+		return a.regex.MatchString(valor)
+	default:
+		return !a.regex.MatchString(valor)
+	}
+}
+
+// Pide -assert-url a traves del proxy y devuelve la primera asercion que no se cumple
+func (vp *VerificadorProxies) EvaluarAserciones(ctx context.Context, tipoProxy, proxy string) (string, bool) {
+	ctx, cancelar := context.WithTimeout(ctx, 2*vp.Timeout)
+	defer cancelar()
+
+	resp, err := vp.GetAtravesDe(ctx, tipoProxy, proxy, vp.URLAserciones)
+	if err != nil {
+		return "request failed", false
+	}
+	defer resp.Body.Close()
+	cuerpo, _ := io.ReadAll(io.LimitReader(resp.Body, maxCuerpoAsercion))
+
+	for _, asercion := range vp.Aserciones {
+		if !asercion.Evaluar(resp, cuerpo) {
+			return asercion.Texto, false
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const largoMaximoBanner = 128
+
+// Lee el resto de la primera linea del banner de un servicio que no hablo el
+// protocolo esperado y deduce de que servicio se trata
+func CapturarBanner(conexion net.Conn, lector io.Reader, leidos []byte) (string, string) {
+	banner := append([]byte(nil), leidos...)
+	conexion.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	bloque := make([]byte, largoMaximoBanner)
+	for len(banner) < largoMaximoBanner && !bytes.ContainsRune(banner, '\n') {
+		n, err := lector.Read(bloque[:largoMaximoBanner-len(banner)])
+		banner = append(banner, bloque[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	return string(banner), ClasificarBanner(banner)
+}
+
+// Deduce el servicio a partir de los primeros bytes recibidos
+func ClasificarBanner(banner []byte) string {
+	texto := strings.ToUpper(string(banner))
+	switch {
+	case strings.HasPrefix(texto, "SSH-"):
+		return "ssh"
+	case strings.HasPrefix(texto, "HTTP/"), strings.HasPrefix(texto, "<!DOCTYPE"), strings.HasPrefix(texto, "<HTML"):
+		return "http"
+	case strings.HasPrefix(texto, "220"):
+		if strings.Contains(texto, "FTP") {
+			return "ftp"
+		}
+		if strings.Contains(texto, "SMTP") || strings.Contains(texto, "MAIL") {
+			return "smtp"
+		}
+		return "ftp/smtp"
+	case strings.HasPrefix(texto, "+OK"):
+		return "pop3"
+	case strings.HasPrefix(texto, "* OK"):
+		return "imap"
+	case strings.HasPrefix(texto, "RFB "):
+		return "vnc"
+	case strings.HasPrefix(texto, "-ERR"), strings.HasPrefix(texto, "-NOAUTH"):
+		return "redis"
+	case bytes.HasPrefix(banner, []byte{0x16, 0x03}), bytes.HasPrefix(banner, []byte{0x15, 0x03}):
+		return "tls"
+	case len(banner) >= 2 && banner[0] == 0x05 && (banner[1] <= 0x02 || banner[1] == 0xFF):
+		return "socks5"
+	case len(banner) >= 2 && (banner[0] == 0x00 || banner[0] == 0x04) && banner[1] >= 0x5A && banner[1] <= 0x5D:
+		return "socks4"
+	default:
+		return "unknown"
+	}
+}
+
+// Guarda proxies/misidentified_services.txt con los puertos que hablan otro
+// servicio, util para depurar listas de fuentes propias
+func (vp *VerificadorProxies) GuardarServiciosMalIdentificados(resultados []Resultado) {
+	dirFinal := "proxies"
+	os.MkdirAll(dirFinal, os.ModePerm)
+	rutaFinal := fmt.Sprintf("%s/misidentified_services.txt", dirFinal)
+
+	archivo, err := os.Create(rutaFinal)
+	if err != nil {
+		vp.Log("ERROR", fmt.Sprintf("No se pudo guardar el reporte de servicios: %v", err))
+		return
+	}
+	defer archivo.Close()
+
+	conteo := make(map[string]int)
+	escritor := bufio.NewWriter(archivo)
+	fmt.Fprintf(escritor, "# run_id %s\n", vp.IDEjecucion)
+	for _, resultado := range resultados {
+		conteo[resultado.Servicio]++
+		fmt.Fprintf(escritor, "%s://%s %s %q\n", resultado.Tipo, resultado.Proxy, resultado.Servicio, resultado.Banner)
+	}
+	escritor.Flush()
+
+	var resumen []string
+	for servicio, cantidad := range conteo {
+		resumen = append(resumen, fmt.Sprintf("%s x%d", servicio, cantidad))
+	}
+	sort.Strings(resumen)
+	vp.Log("INFO", fmt.Sprintf("%d puertos con otro servicio guardados en %s (%s)", len(resultados), rutaFinal, strings.Join(resumen, ", ")))
+}
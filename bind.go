@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// Comprueba si un proxy SOCKS5 acepta el comando BIND (conexiones entrantes,
+// usado por FTP activo y algunas herramientas P2P). Solo espera la primera
+// respuesta, la que anuncia la direccion en la que escucha el proxy
+func (vp *VerificadorProxies) SondearBindSOCKS5(ctx context.Context, proxy string) bool {
+	ctx, cancelar := context.WithTimeout(ctx, vp.Timeout)
+	defer cancelar()
+
+	conexion, err := vp.Conectar(ctx, "tcp", DireccionProxy(proxy))
+	if err != nil {
+		return false
+	}
+	defer conexion.Close()
+	conexion.SetDeadline(time.Now().Add(vp.Timeout))
+
+	usuario, clave, conCredenciales := CredencialesProxy(proxy)
+	saludo := []byte{0x05, 0x01, 0x00}
+	if conCredenciales {
+		saludo = []byte{0x05, 0x02, 0x00, 0x02}
+	}
+	if _, err := conexion.Write(saludo); err != nil {
+		return false
+	}
+	respuesta := make([]byte, 2)
+	if _, err := io.ReadFull(conexion, respuesta); err != nil || respuesta[0] != 0x05 {
+		return false
+	}
+	switch {
+	case respuesta[1] == 0x00:
+	case respuesta[1] == 0x02 && conCredenciales:
+		if !AutenticarSOCKS5(conexion, usuario, clave) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	// DST.ADDR/DST.PORT de BIND es el host del que se espera la conexion entrante
+	ip := net.ParseIP(vp.IPObjetivo).To4()
+	puerto := uint16(vp.PuertoObjetivo)
+	if _, err := conexion.Write([]byte{0x05, 0x02, 0x00, 0x01, ip[0], ip[1], ip[2], ip[3], byte(puerto >> 8), byte(puerto)}); err != nil {
+		return false
+	}
+	respuesta = make([]byte, 4)
+	if _, err := io.ReadFull(conexion, respuesta); err != nil {
+		return false
+	}
+	return respuesta[0] == 0x05 && respuesta[1] == 0x00
+}
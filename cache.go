@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Ultimo resultado conocido de un proxy contra un objetivo, con todo lo que la
+// verificacion deja en Resultado para que un acierto sea igual a verificar
+type EntradaCache struct {
+	Funcional  bool      `json:"working"`
+	Latencia   int64     `json:"latency_ms"`
+	Fecha      time.Time `json:"checked_at"`
+	Validacion string    `json:"validation,omitempty"` // ver ClaveValidacion
+	DatosResultado
+}
+
+func entradaDeResultado(resultado Resultado, validacion string) EntradaCache {
+	return EntradaCache{
+		Funcional:      resultado.Funcional,
+		Latencia:       resultado.Latencia.Milliseconds(),
+		Fecha:          resultado.Fecha,
+		Validacion:     validacion,
+		DatosResultado: datosDeResultado(resultado),
+	}
+}
+
+func (e EntradaCache) resultado(tipoProxy, proxy string) Resultado {
+	resultado := Resultado{
+		Proxy:     proxy,
+		Tipo:      tipoProxy,
+		Funcional: e.Funcional,
+		Latencia:  time.Duration(e.Latencia) * time.Millisecond,
+		Fecha:     e.Fecha,
+	}
+	e.aplicar(&resultado)
+	return resultado
+}
+
+// Opciones que cambian cuando un proxy cuenta como funcional, ademas de
+// -target. Un resultado guardado con otras opciones no se reutiliza
+func (vp *VerificadorProxies) ClaveValidacion() string {
+	partes := []string{"timeout=" + vp.Timeout.String()}
+	// Algunos proxies aceptan o rechazan el CONNECT segun -header y -user-agent
+	nombres := make([]string, 0, len(vp.CabecerasConnect))
+	for nombre := range vp.CabecerasConnect {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	for _, nombre := range nombres {
+		for _, valor := range vp.CabecerasConnect[nombre] {
+			partes = append(partes, "header="+nombre+": "+valor)
+		}
+	}
+	if vp.HTTPEstricto {
+		partes = append(partes, "strict-http")
+	}
+	if vp.SondearBind {
+		partes = append(partes, "probe-bind")
+	}
+	if vp.ObjetivoTLS != "" {
+		partes = append(partes, "tls-target="+vp.ObjetivoTLS)
+	}
+	if len(vp.Aserciones) > 0 {
+		partes = append(partes, "assert-url="+vp.URLAserciones)
+		for _, asercion := range vp.Aserciones {
+			partes = append(partes, "assert="+asercion.Texto)
+		}
+	}
+	if vp.URLConsumo != "" {
+		partes = append(partes, fmt.Sprintf("consumer-url=%s consumer-requests=%d", vp.URLConsumo, vp.SolicitudesConsumo))
+	}
+	return strings.Join(partes, " ")
+}
+
+// Resultados por objetivo, tipo y proxy. Cambiar -target no descarta lo que se
+// sabe de los proxies que solo funcionan contra algunos objetivos
+type CacheObjetivos struct {
+	Ruta       string
+	Objetivo   string        // -target de esta ejecucion
+	Validacion string        // ClaveValidacion de esta ejecucion
+	Vigencia   time.Duration // resultados mas nuevos que esto se reutilizan sin verificar (0 = nunca)
+	Entradas   map[string]map[string]map[string]EntradaCache
+	Aciertos   int64
+	mu         sync.Mutex
+}
+
+// Tiempo tras el cual se olvida un resultado
+const retencionCache = 30 * 24 * time.Hour
+
+// Carga la cache desde disco; si no existe empieza vacia
+func CargarCacheObjetivos(ruta, objetivo string, vigencia time.Duration) (*CacheObjetivos, error) {
+	co := &CacheObjetivos{
+		Ruta:     ruta,
+		Objetivo: objetivo,
+		Vigencia: vigencia,
+		Entradas: make(map[string]map[string]map[string]EntradaCache),
+	}
+
+	datos, err := os.ReadFile(ruta)
+	if os.IsNotExist(err) {
+		return co, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(datos, &co.Entradas); err != nil {
+		return nil, fmt.Errorf("%s: %v", ruta, err)
+	}
+	return co, nil
+}
+
+func (co *CacheObjetivos) buscar(tipoProxy, proxy string) (EntradaCache, bool) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	entrada, ok := co.Entradas[co.Objetivo][tipoProxy][proxy]
+	return entrada, ok
+}
+
+func (co *CacheObjetivos) registrar(resultado Resultado) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	porTipo := co.Entradas[co.Objetivo]
+	if porTipo == nil {
+		porTipo = make(map[string]map[string]EntradaCache)
+		co.Entradas[co.Objetivo] = porTipo
+	}
+	porProxy := porTipo[resultado.Tipo]
+	if porProxy == nil {
+		porProxy = make(map[string]EntradaCache)
+		porTipo[resultado.Tipo] = porProxy
+	}
+	porProxy[resultado.Proxy] = entradaDeResultado(resultado, co.Validacion)
+}
+
+// Middleware que devuelve el resultado guardado para el objetivo actual si tiene
+// menos de Vigencia y se obtuvo con las mismas opciones de validacion, y guarda
+// los resultados nuevos. Va primero en la cadena para que los aciertos no
+// consuman la tasa de verificaciones
+func (co *CacheObjetivos) Middleware(siguiente Verificador) Verificador {
+	return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+		if co.Vigencia > 0 {
+			entrada, ok := co.buscar(tipoProxy, proxy)
+			if ok && entrada.Validacion == co.Validacion && time.Since(entrada.Fecha) < co.Vigencia {
+				atomic.AddInt64(&co.Aciertos, 1)
+				return entrada.resultado(tipoProxy, proxy)
+			}
+		}
+		resultado := siguiente.Verificar(ctx, tipoProxy, proxy)
+		// Cancelada, la verificacion termina como no funcional sin que el proxy
+		// haya fallado: guardarla lo daria por caido durante toda la vigencia
+		if ctx.Err() == nil {
+			co.registrar(resultado)
+		}
+		return resultado
+	})
+}
+
+// Guarda la cache descartando resultados de mas de 30 dias
+func (co *CacheObjetivos) Guardar(ahora time.Time) error {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	for objetivo, porTipo := range co.Entradas {
+		for tipoProxy, porProxy := range porTipo {
+			for proxy, entrada := range porProxy {
+				if ahora.Sub(entrada.Fecha) > retencionCache {
+					delete(porProxy, proxy)
+				}
+			}
+			if len(porProxy) == 0 {
+				delete(porTipo, tipoProxy)
+			}
+		}
+		if len(porTipo) == 0 {
+			delete(co.Entradas, objetivo)
+		}
+	}
+
+	datos, err := json.Marshal(co.Entradas)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(co.Ruta), os.ModePerm)
+	temporal := co.Ruta + ".tmp"
+	if err := os.WriteFile(temporal, datos, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporal, co.Ruta)
+}
+
+// Escribe proxies/targets/<objetivo>/<TIPO>.txt con los proxies que funcionaban
+// en su ultima verificacion contra cada objetivo de la cache
+func (co *CacheObjetivos) GuardarListasPorObjetivo(dirBase string) error {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	for objetivo, porTipo := range co.Entradas {
+		dirObjetivo := filepath.Join(dirBase, strings.NewReplacer(":", "_", "[", "", "]", "", "/", "_").Replace(objetivo))
+		if err := os.MkdirAll(dirObjetivo, os.ModePerm); err != nil {
+			return err
+		}
+		for tipoProxy, porProxy := range porTipo {
+			var funcionales []string
+			for proxy, entrada := range porProxy {
+				if entrada.Funcional {
+					funcionales = append(funcionales, proxy)
+				}
+			}
+			sort.Strings(funcionales)
+			contenido := ""
+			if len(funcionales) > 0 {
+				contenido = strings.Join(funcionales, "\n") + "\n"
+			}
+			ruta := filepath.Join(dirObjetivo, strings.ToUpper(tipoProxy)+".txt")
+			if err := os.WriteFile(ruta, []byte(contenido), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
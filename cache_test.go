@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheObjetivosMiddleware(t *testing.T) {
+	guardado := Resultado{Proxy: "1.2.3.4:1080", Tipo: "socks5", Funcional: true, Latencia: 150 * time.Millisecond, Autenticacion: "none"}
+	casos := []struct {
+		nombre     string
+		vigencia   time.Duration
+		antiguedad time.Duration
+		validacion string // ClaveValidacion con la que se guardo el resultado
+		acierto    bool
+	}{
+		{nombre: "vigente", vigencia: time.Hour, antiguedad: time.Minute, acierto: true},
+		{nombre: "vencido", vigencia: time.Hour, antiguedad: 2 * time.Hour},
+		{nombre: "sin vigencia", vigencia: 0, antiguedad: time.Minute},
+		{nombre: "otra validacion", vigencia: time.Hour, antiguedad: time.Minute, validacion: "strict-http"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			cache, err := CargarCacheObjetivos(filepath.Join(t.TempDir(), "cache.json"), "1.1.1.1:80", caso.vigencia)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cache.Validacion = caso.validacion
+			anterior := guardado
+			anterior.Fecha = time.Now().Add(-caso.antiguedad)
+			cache.registrar(anterior)
+			cache.Validacion = ""
+
+			llamadas := 0
+			base := FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+				llamadas++
+				return Resultado{Proxy: proxy, Tipo: tipoProxy, ClaseError: ErrorTimeout, Fecha: time.Now()}
+			})
+			resultado := cache.Middleware(base).Verificar(context.Background(), "socks5", guardado.Proxy)
+
+			if caso.acierto {
+				if llamadas != 0 || cache.Aciertos != 1 {
+					t.Fatalf("se esperaba un acierto sin verificar: %d llamadas, %d aciertos", llamadas, cache.Aciertos)
+				}
+				if !resultado.Funcional || resultado.Latencia != guardado.Latencia || resultado.Autenticacion != "none" {
+					t.Errorf("acierto %+v no reproduce el resultado guardado", resultado)
+				}
+				return
+			}
+			if llamadas != 1 || cache.Aciertos != 0 {
+				t.Fatalf("se esperaba verificar de nuevo: %d llamadas, %d aciertos", llamadas, cache.Aciertos)
+			}
+			// El resultado nuevo reemplaza al guardado
+			if entrada, _ := cache.buscar("socks5", guardado.Proxy); entrada.Funcional || entrada.ClaseError != ErrorTimeout {
+				t.Errorf("la cache conserva %+v en lugar del resultado nuevo", entrada)
+			}
+		})
+	}
+}
+
+func TestCacheObjetivosNoGuardaCancelados(t *testing.T) {
+	cache, err := CargarCacheObjetivos(filepath.Join(t.TempDir(), "cache.json"), "1.1.1.1:80", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancelar := context.WithCancel(context.Background())
+	base := FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+		cancelar()
+		return Resultado{Proxy: proxy, Tipo: tipoProxy, ClaseError: ErrorCancelado}
+	})
+	cache.Middleware(base).Verificar(ctx, "http", "1.2.3.4:8080")
+	if _, ok := cache.buscar("http", "1.2.3.4:8080"); ok {
+		t.Error("se guardo una verificacion cancelada")
+	}
+}
+
+func TestCacheObjetivosGuardar(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := CargarCacheObjetivos(ruta, "1.1.1.1:80", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ahora := time.Now()
+	cache.registrar(Resultado{Proxy: "1.2.3.4:8080", Tipo: "http", Funcional: true, Estado: "200", Fecha: ahora})
+	cache.registrar(Resultado{Proxy: "5.6.7.8:8080", Tipo: "http", Funcional: true, Fecha: ahora.Add(-2 * retencionCache)})
+	if err := cache.Guardar(ahora); err != nil {
+		t.Fatal(err)
+	}
+
+	cargada, err := CargarCacheObjetivos(ruta, "1.1.1.1:80", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entrada, ok := cargada.buscar("http", "1.2.3.4:8080"); !ok || !entrada.Funcional || entrada.Estado != "200" {
+		t.Errorf("entrada reciente = %+v, %t", entrada, ok)
+	}
+	if _, ok := cargada.buscar("http", "5.6.7.8:8080"); ok {
+		t.Error("se conservo una entrada de mas de 30 dias")
+	}
+}
+
+func TestClaveValidacion(t *testing.T) {
+	clave := func(timeout time.Duration, cabeceras ...string) string {
+		vp := &VerificadorProxies{Timeout: timeout, CabecerasConnect: make(http.Header)}
+		for i := 0; i+1 < len(cabeceras); i += 2 {
+			vp.CabecerasConnect.Add(cabeceras[i], cabeceras[i+1])
+		}
+		return vp.ClaveValidacion()
+	}
+	base := clave(5*time.Second, "X-Uno", "1", "User-Agent", "curl/8")
+	if otra := clave(5*time.Second, "User-Agent", "curl/8", "X-Uno", "1"); otra != base {
+		t.Errorf("el orden de las cabeceras cambio la clave: %q y %q", base, otra)
+	}
+	distintas := []string{
+		clave(10*time.Second, "X-Uno", "1", "User-Agent", "curl/8"),
+		clave(5*time.Second, "X-Uno", "1", "User-Agent", "Mozilla/5.0"),
+		clave(5*time.Second, "X-Uno", "2", "User-Agent", "curl/8"),
+		clave(5*time.Second, "User-Agent", "curl/8"),
+	}
+	for _, otra := range distintas {
+		if otra == base {
+			t.Errorf("se esperaba una clave distinta de %q", base)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Hace una solicitud real a -warm-url a traves de cada proxy funcional justo
+// antes de exportar y descarta los que fallan, para no publicar proxies que
+// murieron entre la verificacion y la publicacion
+func (vp *VerificadorProxies) Calentar(ctx context.Context, resultados []Resultado, maxChecks int) {
+	var wg sync.WaitGroup
+	var descartados int64
+	var mu sync.Mutex
+	tokens := make(chan struct{}, vp.trabajadores(maxChecks))
+
+	for i := range resultados {
+		if !resultados[i].Funcional {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(resultado *Resultado) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+			if err := vp.SolicitudCalentamiento(ctx, resultado.Tipo, resultado.Proxy); err != nil {
+				resultado.Funcional = false
+				mu.Lock()
+				descartados++
+				mu.Unlock()
+			}
+		}(&resultados[i])
+	}
+	wg.Wait()
+
+	if descartados > 0 {
+		vp.Log("WARNING", fmt.Sprintf("%d proxies descartados por fallar la solicitud de calentamiento a %s", descartados, vp.URLCalentamiento))
+	}
+}
+
+// Pide vp.URLCalentamiento a traves del proxy; cualquier respuesta HTTP cuenta como exito
+func (vp *VerificadorProxies) SolicitudCalentamiento(ctx context.Context, tipoProxy, proxy string) error {
+	ctx, cancelar := context.WithTimeout(ctx, 2*vp.Timeout)
+	defer cancelar()
+	resp, err := vp.GetAtravesDe(ctx, tipoProxy, proxy, vp.URLCalentamiento)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.CopyN(io.Discard, resp.Body, 1024)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// Hace un GET a traves del proxy con el User-Agent configurado
+func (vp *VerificadorProxies) GetAtravesDe(ctx context.Context, tipoProxy, proxy, direccion string) (*http.Response, error) {
+	transporte, err := vp.TransporteAtravesDe(tipoProxy, proxy)
+	if err != nil {
+		return nil, err
+	}
+	transporte.DisableKeepAlives = true
+
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, direccion, nil)
+	if err != nil {
+		return nil, err
+	}
+	if agente := vp.CabecerasConnect.Get("User-Agent"); agente != "" {
+		solicitud.Header.Set("User-Agent", agente)
+	}
+	// Las redirecciones no se siguen: las aserciones evaluan la primera respuesta
+	cliente := &http.Client{
+		Transport:     transporte,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return cliente.Do(solicitud)
+}
+
+// Transporte HTTP que sale a traves del proxy
+func (vp *VerificadorProxies) TransporteAtravesDe(tipoProxy, proxy string) (*http.Transport, error) {
+	transporte := &http.Transport{
+		DialContext:         vp.Conectar,
+		TLSHandshakeTimeout: vp.Timeout,
+	}
+	p, err := ParsearProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	urlProxy := &url.URL{Host: p.Direccion()}
+	if p.ConCredenciales() {
+		urlProxy.User = url.UserPassword(p.Usuario, p.Clave)
+	}
+
+	switch tipoProxy {
+	case "http", "socks5":
+		urlProxy.Scheme = tipoProxy
+		transporte.Proxy = http.ProxyURL(urlProxy)
+	case "socks4":
+		transporte.DialContext = func(ctx context.Context, red, direccion string) (net.Conn, error) {
+			return vp.ConectarSOCKS4(ctx, urlProxy.Host, direccion)
+		}
+	default:
+		return nil, fmt.Errorf("tipo de proxy desconocido %q", tipoProxy)
+	}
+	return transporte, nil
+}
+
+// Abre un tunel SOCKS4 hasta direccion (host:puerto, se resuelve localmente a IPv4)
+func (vp *VerificadorProxies) ConectarSOCKS4(ctx context.Context, proxy, direccion string) (net.Conn, error) {
+	host, puertoTexto, err := net.SplitHostPort(direccion)
+	if err != nil {
+		return nil, err
+	}
+	puerto, err := strconv.Atoi(puertoTexto)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	ip := ips[0].To4()
+
+	conexion, err := vp.Conectar(ctx, "tcp", proxy)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conexion.SetDeadline(deadline)
+	}
+	solicitud := []byte{0x04, 0x01, byte(puerto >> 8), byte(puerto), ip[0], ip[1], ip[2], ip[3], 0x00}
+	if _, err := conexion.Write(solicitud); err != nil {
+		conexion.Close()
+		return nil, err
+	}
+	respuesta := make([]byte, 8)
+	if _, err := io.ReadFull(conexion, respuesta); err != nil {
+		conexion.Close()
+		return nil, err
+	}
+	if respuesta[1] != 0x5A {
+		conexion.Close()
+		return nil, fmt.Errorf("socks4: conexion rechazada (0x%02X)", respuesta[1])
+	}
+	conexion.SetDeadline(time.Time{})
+	return conexion, nil
+}
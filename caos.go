@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/lilsheepyy/proxy-scrapper-checker/testutil"
+)
+
+// Proxy simulado con la clasificacion que deberia obtener
+type casoCaos struct {
+	servidor     *testutil.Servidor
+	funcional    bool
+	requiereAuth bool
+	servicio     string
+	descripcion  string
+}
+
+// Modo caos: verifica proxies simulados con retardos, lecturas cortas y resets
+// inyectados en una fraccion de ellos y comprueba que se clasifiquen bien
+func (vp *VerificadorProxies) EjecutarCaos(ctx context.Context, fraccion float64, maxChecks int) bool {
+	correcto := true
+	for _, tipoProxy := range []string{"socks4", "socks5", "http"} {
+		if ctx.Err() != nil {
+			return false
+		}
+		vp.Log("INFO", fmt.Sprintf("Modo caos: verificando proxies %s simulados (fraccion %.2f)", strings.ToUpper(tipoProxy), fraccion))
+
+		casos, err := vp.crearCasosCaos(tipoProxy, fraccion, 40)
+		if err != nil {
+			vp.Log("ERROR", fmt.Sprintf("No se pudieron iniciar servidores simulados: %v", err))
+			return false
+		}
+
+		direcciones := make([]string, len(casos))
+		for i, caso := range casos {
+			direcciones[i] = caso.servidor.Direccion
+		}
+		resultados := vp.VerificarLista(ctx, tipoProxy, direcciones, maxChecks, nil)
+		if tipoProxy == "http" {
+			vp.LogResumenEstadosHTTP(resultados)
+		}
+		porProxy := make(map[string]Resultado)
+		for _, resultado := range resultados {
+			porProxy[resultado.Proxy] = resultado
+		}
+
+		errores := 0
+		for _, caso := range casos {
+			resultado := porProxy[caso.servidor.Direccion]
+			requiereAuth := resultado.Autenticacion == "required"
+			if resultado.Funcional != caso.funcional || requiereAuth != caso.requiereAuth || resultado.Servicio != caso.servicio {
+				errores++
+				vp.Log("ERROR", fmt.Sprintf("%s (%s): esperado funcional=%t auth=%t servicio=%q, obtenido funcional=%t auth=%t servicio=%q",
+					caso.servidor.Direccion, caso.descripcion, caso.funcional, caso.requiereAuth, caso.servicio, resultado.Funcional, requiereAuth, resultado.Servicio))
+			}
+			caso.servidor.Cerrar()
+		}
+
+		if errores > 0 {
+			correcto = false
+			vp.Log("ERROR", fmt.Sprintf("Modo caos %s: %d de %d proxies mal clasificados", strings.ToUpper(tipoProxy), errores, len(casos)))
+		} else {
+			vp.Log("INFO", fmt.Sprintf("Modo caos %s: %d proxies clasificados correctamente", strings.ToUpper(tipoProxy), len(casos)))
+		}
+	}
+	return correcto
+}
+
+func (vp *VerificadorProxies) crearCasosCaos(tipoProxy string, fraccion float64, cantidad int) ([]casoCaos, error) {
+	var casos []casoCaos
+	for i := 0; i < cantidad; i++ {
+		comportamiento := testutil.Comportamiento{Protocolo: tipoProxy}
+		funcional := true
+		var descripcion []string
+
+		// Comportamientos base, sin caos
+		switch i % 4 {
+		case 0:
+			descripcion = append(descripcion, "normal")
+			if tipoProxy == "http" && i%8 == 0 {
+				comportamiento.LineaEstado = "HTTP/1.0 200 OK"
+				funcional = !vp.HTTPEstricto
+				descripcion = append(descripcion, "HTTP/1.0")
+			}
+		case 1:
+			comportamiento.Rechazar = true
+			funcional = false
+			descripcion = append(descripcion, "rechaza")
+		case 2:
+			comportamiento.Malformado = true
+			funcional = false
+			descripcion = append(descripcion, "malformado")
+		case 3:
+			comportamiento.RequiereAuth = true
+			funcional = false
+			descripcion = append(descripcion, "requiere auth")
+		}
+
+		// Perturbaciones inyectadas en una fraccion de los proxies
+		if rand.Float64() < fraccion {
+			switch rand.Intn(4) {
+			case 0:
+				comportamiento.Retardo = vp.Timeout / 4
+				descripcion = append(descripcion, "retardo corto")
+			case 1:
+				comportamiento.Retardo = vp.Timeout * 2
+				funcional = false
+				descripcion = append(descripcion, "retardo mayor al timeout")
+			case 2:
+				comportamiento.Fragmentar = true
+				descripcion = append(descripcion, "lecturas cortas")
+			case 3:
+				comportamiento.Reiniciar = true
+				funcional = false
+				descripcion = append(descripcion, "reset")
+			}
+		}
+
+		// SOCKS5 y HTTP informan que piden credenciales y los malformados el
+		// servicio que imitan, siempre que la respuesta llegue
+		responde := !comportamiento.Reiniciar && comportamiento.Retardo < vp.Timeout
+		requiereAuth := comportamiento.RequiereAuth && tipoProxy != "socks4" && responde
+		servicio := ""
+		if comportamiento.Malformado && responde {
+			servicio = map[string]string{"socks4": "ssh", "socks5": "http", "http": "smtp"}[tipoProxy]
+		}
+
+		servidor, err := testutil.IniciarServidor(comportamiento)
+		if err != nil {
+			for _, caso := range casos {
+				caso.servidor.Cerrar()
+			}
+			return nil, err
+		}
+		casos = append(casos, casoCaos{servidor: servidor, funcional: funcional, requiereAuth: requiereAuth, servicio: servicio, descripcion: strings.Join(descripcion, ", ")})
+	}
+	return casos, nil
+}
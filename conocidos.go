@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// Lee una lista de proxies que el usuario ya tiene (-exclude-known). Las lineas
+// se normalizan igual que las fuentes y se comparan por host:puerto
+func CargarProxiesConocidos(ruta string, reglas ReglasCanonicas) (map[string]struct{}, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, err
+	}
+	defer archivo.Close()
+
+	conocidos := make(map[string]struct{})
+	scanner := bufio.NewScanner(archivo)
+	for scanner.Scan() {
+		if proxy, _ := separarLineaProxy(scanner.Text(), reglas); proxy != "" {
+			conocidos[DireccionProxy(proxy)] = struct{}{}
+		}
+	}
+	return conocidos, scanner.Err()
+}
+
+// Descarta los proxies que ya estan en la lista de -exclude-known
+func (vp *VerificadorProxies) FiltrarConocidos(tipoProxy string, proxies []string) []string {
+	if len(vp.ProxiesConocidos) == 0 {
+		return proxies
+	}
+	var nuevos []string
+	for _, proxy := range proxies {
+		if _, ok := vp.ProxiesConocidos[DireccionProxy(proxy)]; !ok {
+			nuevos = append(nuevos, proxy)
+		}
+	}
+	if descartados := len(proxies) - len(nuevos); descartados > 0 {
+		vp.Log("INFO", fmt.Sprintf("%d proxies %s omitidos por estar en la lista de proxies conocidos", descartados, tipoProxy))
+	}
+	return nuevos
+}
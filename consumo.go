@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+)
+
+// User-Agent de navegador para la simulacion de consumo si no se configuro otro
+const agenteNavegador = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+// Bytes maximos que se leen de cada respuesta de la simulacion
+const maxCuerpoConsumo = 1 << 20
+
+// Simula un uso real del proxy: vp.SolicitudesConsumo GETs seguidos a
+// vp.URLConsumo por la misma conexion, con cookies, gzip, redirecciones y
+// pausas cortas entre uno y otro. Devuelve "" si el proxy aguanta todas o la
+// descripcion de la primera que fallo
+func (vp *VerificadorProxies) SimularConsumo(ctx context.Context, tipoProxy, proxy string) string {
+	transporte, err := vp.TransporteAtravesDe(tipoProxy, proxy)
+	if err != nil {
+		return err.Error()
+	}
+	defer transporte.CloseIdleConnections()
+	galletas, _ := cookiejar.New(nil)
+	cliente := &http.Client{Transport: transporte, Jar: galletas}
+
+	agente := vp.CabecerasConnect.Get("User-Agent")
+	if agente == "" {
+		agente = agenteNavegador
+	}
+
+	for i := 1; i <= vp.SolicitudesConsumo; i++ {
+		if i > 1 {
+			pausa := 200*time.Millisecond + time.Duration(rand.Int63n(int64(600*time.Millisecond)))
+			select {
+			case <-time.After(pausa):
+			case <-ctx.Done():
+				return "cancelado"
+			}
+		}
+		if err := vp.solicitudConsumo(ctx, cliente, agente); err != nil {
+			return fmt.Sprintf("solicitud %d de %d: %v", i, vp.SolicitudesConsumo, err)
+		}
+	}
+	return ""
+}
+
+func (vp *VerificadorProxies) solicitudConsumo(ctx context.Context, cliente *http.Client, agente string) error {
+	ctx, cancelar := context.WithTimeout(ctx, 2*vp.Timeout)
+	defer cancelar()
+
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, vp.URLConsumo, nil)
+	if err != nil {
+		return err
+	}
+	// Sin Accept-Encoding explicito el transporte pide gzip y lo descomprime, como un navegador
+	solicitud.Header.Set("User-Agent", agente)
+	solicitud.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	solicitud.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := cliente.Do(solicitud)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("respondio %s", resp.Status)
+	}
+	// Leer el cuerpo completo detecta proxies que cortan o corrompen respuestas comprimidas
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, maxCuerpoConsumo)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fallos seguidos tras los que se deja de consultar un servicio de enriquecimiento
+const fallosInterruptor = 5
+
+// Tiempo que un servicio queda sin consultar despues de abrir su interruptor
+const pausaInterruptor = time.Minute
+
+// Interruptor de circuito para un servicio de enriquecimiento: tras varios
+// fallos seguidos deja de consultarlo un rato para no frenar la ejecucion
+type Interruptor struct {
+	Nombre       string
+	mu           sync.Mutex
+	fallos       int
+	abiertoHasta time.Time
+}
+
+// Indica si se puede consultar el servicio
+func (in *Interruptor) Permitir() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return !time.Now().Before(in.abiertoHasta)
+}
+
+// Registra el resultado de una consulta; devuelve true si con este fallo se abrio
+func (in *Interruptor) Registrar(err error) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err == nil {
+		in.fallos = 0
+		return false
+	}
+	in.fallos++
+	if in.fallos < fallosInterruptor {
+		return false
+	}
+	// Pasada la pausa se permite una consulta; si vuelve a fallar se abre de nuevo
+	in.abiertoHasta = time.Now().Add(pausaInterruptor)
+	return true
+}
+
+// Contexto para una etapa de enriquecimiento limitado por -enrich-timeout
+func (vp *VerificadorProxies) contextoEnriquecimiento(ctx context.Context) (context.Context, context.CancelFunc) {
+	if vp.TimeoutEnriquecimiento <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, vp.TimeoutEnriquecimiento)
+}
+
+// Agrega a Faltantes el nombre de un enriquecimiento que no se pudo completar
+func (r *Resultado) marcarFaltante(nombre string) {
+	for _, faltante := range r.Faltantes {
+		if faltante == nombre {
+			return
+		}
+	}
+	r.Faltantes = append(r.Faltantes, nombre)
+}
+
+// Busca el pais de cada proxy verificado en -geoip-db. La base es local, asi que
+// una consulta solo falla si se agota -enrich-timeout o se cancela la ejecucion;
+// igual pasa por un interruptor como las demas etapas y lo que no se consulto
+// queda marcado con "geoip" en Faltantes
+func (vp *VerificadorProxies) EnriquecerConGeoIP(ctx context.Context, resultados []Resultado) {
+	if vp.GeoIP == nil {
+		return
+	}
+	ctx, cancelar := vp.contextoEnriquecimiento(ctx)
+	defer cancelar()
+
+	interruptor := &Interruptor{Nombre: "geoip"}
+	faltantes := 0
+	for i := range resultados {
+		pais, err := vp.consultarGeoIP(ctx, interruptor, resultados[i].Proxy)
+		if err != nil {
+			faltantes++
+			resultados[i].marcarFaltante("geoip")
+			continue
+		}
+		resultados[i].Pais = pais
+	}
+
+	if faltantes > 0 {
+		vp.Log("WARNING", fmt.Sprintf("GeoIP incompleto: %d proxies quedaron sin consultar", faltantes))
+	}
+}
+
+func (vp *VerificadorProxies) consultarGeoIP(ctx context.Context, interruptor *Interruptor, proxy string) (string, error) {
+	if !interruptor.Permitir() {
+		return "", errors.New("interruptor abierto")
+	}
+	if err := ctx.Err(); err != nil {
+		if interruptor.Registrar(err) {
+			vp.Log("WARNING", fmt.Sprintf("GeoIP fallo %d veces seguidas, se pausa %s", fallosInterruptor, pausaInterruptor))
+		}
+		return "", err
+	}
+	interruptor.Registrar(nil)
+	return vp.GeoIP.Pais(proxy), nil
+}
+
+// Busca el nombre reverso (PTR) de la IP de cada proxy funcional. Es best-effort:
+// si el DNS falla seguido o se agota -enrich-timeout, los resultados que
+// faltan quedan marcados con "rdns" en Faltantes
+func (vp *VerificadorProxies) EnriquecerConRDNS(ctx context.Context, resultados []Resultado) {
+	if !vp.RDNS {
+		return
+	}
+	ctx, cancelar := vp.contextoEnriquecimiento(ctx)
+	defer cancelar()
+
+	interruptor := &Interruptor{Nombre: "rdns"}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	tokens := make(chan struct{}, 20)
+	faltantes := 0
+
+	for i := range resultados {
+		if !resultados[i].Funcional {
+			continue
+		}
+		ip, _, err := net.SplitHostPort(DireccionProxy(resultados[i].Proxy))
+		if err != nil {
+			continue
+		}
+
+		// El token se toma antes de lanzar la gorutina, asi no quedan miles
+		// esperando turno con muchos proxies funcionales
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(resultado *Resultado, ip string) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+
+			nombre, err := vp.consultarRDNS(ctx, interruptor, ip)
+			if err != nil {
+				mu.Lock()
+				faltantes++
+				resultado.marcarFaltante("rdns")
+				mu.Unlock()
+				return
+			}
+			resultado.RDNS = nombre
+		}(&resultados[i], ip)
+	}
+	wg.Wait()
+
+	if faltantes > 0 {
+		vp.Log("WARNING", fmt.Sprintf("rDNS incompleto: %d proxies quedaron sin consultar o fallaron", faltantes))
+	}
+}
+
+func (vp *VerificadorProxies) consultarRDNS(ctx context.Context, interruptor *Interruptor, ip string) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if !interruptor.Permitir() {
+		return "", errors.New("interruptor abierto")
+	}
+
+	ctxConsulta, cancelar := context.WithTimeout(ctx, vp.Timeout)
+	defer cancelar()
+	nombres, err := net.DefaultResolver.LookupAddr(ctxConsulta, ip)
+	// Que la IP no tenga PTR es una respuesta valida, no un fallo del DNS
+	var errorDNS *net.DNSError
+	if errors.As(err, &errorDNS) && errorDNS.IsNotFound {
+		interruptor.Registrar(nil)
+		return "", nil
+	}
+	if interruptor.Registrar(err) {
+		vp.Log("WARNING", fmt.Sprintf("rDNS fallo %d veces seguidas, se pausa %s", fallosInterruptor, pausaInterruptor))
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(nombres) == 0 {
+		return "", nil
+	}
+	return strings.TrimSuffix(nombres[0], "."), nil
+}
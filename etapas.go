@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Etapas del pipeline en el orden en que se reportan
+var OrdenEtapas = []string{"scrape", "sanitize", "check", "warm", "enrich", "export"}
+
+// Tiempo de reloj por etapa de una ejecucion. Los tipos se procesan uno tras
+// otro, asi que las etapas suman aproximadamente la duracion total. Dentro de
+// "check" ademas se suma el tiempo de los workers en handshake y en validacion
+// (BIND, -tls-target, -assert), que puede superar al de reloj por la concurrencia.
+// Un *TiemposEtapas nil no mide nada
+type TiemposEtapas struct {
+	mu         sync.Mutex
+	duraciones map[string]time.Duration
+	handshake  int64 // nanosegundos sumados entre workers
+	validacion int64
+}
+
+func NuevosTiemposEtapas() *TiemposEtapas {
+	return &TiemposEtapas{duraciones: make(map[string]time.Duration)}
+}
+
+// Empieza a medir una etapa; la funcion devuelta la termina
+func (te *TiemposEtapas) Medir(etapa string) func() {
+	inicio := time.Now()
+	return func() { te.Agregar(etapa, time.Since(inicio)) }
+}
+
+func (te *TiemposEtapas) Agregar(etapa string, duracion time.Duration) {
+	if te == nil {
+		return
+	}
+	te.mu.Lock()
+	te.duraciones[etapa] += duracion
+	te.mu.Unlock()
+}
+
+// Suma lo que tardo un worker en el handshake y en la validacion de un proxy
+func (te *TiemposEtapas) SumarVerificacion(handshake, validacion time.Duration) {
+	if te == nil {
+		return
+	}
+	atomic.AddInt64(&te.handshake, int64(handshake))
+	atomic.AddInt64(&te.validacion, int64(validacion))
+}
+
+// Duracion acumulada de una etapa
+func (te *TiemposEtapas) Duracion(etapa string) time.Duration {
+	if te == nil {
+		return 0
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	return te.duraciones[etapa]
+}
+
+// Una linea con cada etapa, su porcentaje sobre total y el detalle de los workers
+func (te *TiemposEtapas) Resumen(total time.Duration) string {
+	if te == nil {
+		return ""
+	}
+	var partes []string
+	for _, etapa := range OrdenEtapas {
+		duracion := te.Duracion(etapa)
+		if duracion == 0 {
+			continue
+		}
+		parte := fmt.Sprintf("%s %s", etapa, redondear(duracion))
+		if total > 0 {
+			parte += fmt.Sprintf(" (%.0f%%)", float64(duracion)*100/float64(total))
+		}
+		if etapa == "check" {
+			parte += fmt.Sprintf(" [workers: handshake %s, validacion %s]",
+				redondear(time.Duration(atomic.LoadInt64(&te.handshake))),
+				redondear(time.Duration(atomic.LoadInt64(&te.validacion))))
+		}
+		partes = append(partes, parte)
+	}
+	return strings.Join(partes, ", ")
+}
+
+// Milisegundos para duraciones largas y microsegundos para las menores a 1ms
+func redondear(duracion time.Duration) time.Duration {
+	if duracion < time.Millisecond {
+		return duracion.Round(time.Microsecond)
+	}
+	return duracion.Round(time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxElementosPorFeed = 20  // enlaces nuevos que se intentan descargar en cada lectura
+	maxElementosVistos  = 500 // enlaces recordados por feed para no volver a seguirlos
+)
+
+// Canal RSS o feed Atom; los campos que no corresponden al formato quedan vacios
+type feedXML struct {
+	Items []struct {
+		Enlace string `xml:"link"`
+		GUID   string `xml:"guid"`
+	} `xml:"channel>item"`
+	Entradas []struct {
+		ID      string `xml:"id"`
+		Enlaces []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Enlaces de los elementos del feed, en el orden en que aparecen
+func (f feedXML) enlaces() []string {
+	var enlaces []string
+	for _, item := range f.Items {
+		if item.Enlace != "" {
+			enlaces = append(enlaces, strings.TrimSpace(item.Enlace))
+		} else if strings.HasPrefix(item.GUID, "http") {
+			enlaces = append(enlaces, strings.TrimSpace(item.GUID))
+		}
+	}
+	for _, entrada := range f.Entradas {
+		for _, enlace := range entrada.Enlaces {
+			if enlace.Rel == "" || enlace.Rel == "alternate" {
+				enlaces = append(enlaces, strings.TrimSpace(enlace.Href))
+				break
+			}
+		}
+	}
+	return enlaces
+}
+
+// Direcciones ip:puerto dentro de texto o HTML, tambien cuando ip y puerto
+// estan en celdas separadas de una tabla
+var proxyEnTexto = regexp.MustCompile(`\b((?:\d{1,3}\.){3}\d{1,3})(?:\s*:\s*|\s+)(\d{2,5})\b`)
+var etiquetaHTML = regexp.MustCompile(`<[^>]*>`)
+
+// Extrae una linea ip:puerto por cada proxy encontrado en una pagina
+func ExtraerProxiesDeTexto(texto string) []string {
+	texto = etiquetaHTML.ReplaceAllString(texto, " ")
+	var proxies []string
+	for _, coincidencia := range proxyEnTexto.FindAllStringSubmatch(texto, -1) {
+		proxies = append(proxies, coincidencia[1]+":"+coincidencia[2])
+	}
+	return proxies
+}
+
+// Lee un feed RSS/Atom, sigue los enlaces de los elementos que no se vieron en
+// lecturas anteriores y extrae los proxies de esas paginas y del propio feed.
+// Un enlace solo queda como visto si se pudo descargar; los ya vistos no se
+// vuelven a descargar ni aportan proxies
+func (vp *VerificadorProxies) LeerFeed(ctx context.Context, cliente *http.Client, direccion string) ([]string, error) {
+	cuerpo, err := vp.descargarTexto(ctx, cliente, direccion)
+	if err != nil {
+		return nil, err
+	}
+	var feed feedXML
+	if err := xml.Unmarshal(cuerpo, &feed); err != nil {
+		return nil, fmt.Errorf("feed invalido: %v", err)
+	}
+	base, _ := url.Parse(direccion)
+
+	var cursor Cursor
+	if vp.Cursores != nil {
+		cursor = vp.Cursores.Obtener(direccion)
+	}
+	vistos := make(map[string]bool)
+	for _, enlace := range cursor.Elementos {
+		vistos[enlace] = true
+	}
+
+	proxies := ExtraerProxiesDeTexto(string(cuerpo))
+	var seguidos []string
+	intentos := 0
+	for _, enlace := range feed.enlaces() {
+		if intentos >= maxElementosPorFeed || ctx.Err() != nil {
+			break
+		}
+		if destino, err := base.Parse(enlace); err == nil {
+			enlace = destino.String()
+		}
+		if vistos[enlace] {
+			continue
+		}
+		vistos[enlace] = true
+
+		if err := vp.FuentePermitida(enlace); err != nil {
+			vp.Log("WARNING", fmt.Sprintf("Enlace %s del feed omitido: %v", enlace, err))
+			continue
+		}
+		intentos++
+		pagina, err := vp.descargarTexto(ctx, cliente, enlace)
+		if err != nil {
+			// No se marca como visto: se vuelve a intentar en la proxima lectura
+			vp.Log("WARNING", fmt.Sprintf("No se pudo leer %s del feed %s: %v", enlace, direccion, err))
+			continue
+		}
+		seguidos = append(seguidos, enlace)
+		proxies = append(proxies, ExtraerProxiesDeTexto(string(pagina))...)
+	}
+
+	if vp.Cursores != nil {
+		cursor.Elementos = append(cursor.Elementos, seguidos...)
+		if len(cursor.Elementos) > maxElementosVistos {
+			cursor.Elementos = cursor.Elementos[len(cursor.Elementos)-maxElementosVistos:]
+		}
+		vp.Cursores.Actualizar(direccion, cursor)
+	}
+	vp.Log("INFO", fmt.Sprintf("Feed %s: %d elementos nuevos, %d proxies", direccion, len(seguidos), len(proxies)))
+	return proxies, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tiempo maximo para descargar un archivo por FTP o SFTP
+const tiempoMaximoTransferencia = 2 * time.Minute
+
+// Usuario y clave de la URL o, si no estan, de las opciones de la fuente
+func credencialesFuente(u *url.URL, fuente Fuente) (string, string) {
+	if u.User != nil {
+		clave, _ := u.User.Password()
+		return u.User.Username(), clave
+	}
+	return fuente.Usuario, fuente.Clave
+}
+
+// Descarga un archivo por FTP en modo pasivo. Sin credenciales entra como anonymous
+func (vp *VerificadorProxies) LeerFTP(ctx context.Context, direccion string, fuente Fuente) ([]string, error) {
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	usuario, clave := credencialesFuente(u, fuente)
+	if usuario == "" {
+		usuario, clave = "anonymous", "anonymous@"
+	}
+	// Un CR o LF en la ruta o las credenciales terminaria el comando y
+	// permitiria mandar otros al servidor
+	ruta := strings.TrimPrefix(u.Path, "/")
+	if strings.ContainsAny(ruta+usuario+clave, "\r\n") {
+		return nil, fmt.Errorf("ftp: la ruta o las credenciales tienen saltos de linea")
+	}
+
+	ctx, cancelar := context.WithTimeout(ctx, tiempoMaximoTransferencia)
+	defer cancelar()
+	conexion, err := vp.Conectar(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conexion.SetDeadline(deadline)
+	control := textproto.NewConn(conexion)
+	defer control.Close()
+
+	if _, _, err := control.ReadResponse(220); err != nil {
+		return nil, err
+	}
+	codigo, mensaje, err := comandoFTP(control, 0, "USER %s", usuario)
+	if err != nil {
+		return nil, err
+	}
+	switch codigo {
+	case 230:
+	case 331:
+		if _, _, err := comandoFTP(control, 230, "PASS %s", clave); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("ftp: USER rechazado: %d %s", codigo, mensaje)
+	}
+	if _, _, err := comandoFTP(control, 200, "TYPE I"); err != nil {
+		return nil, err
+	}
+
+	_, mensaje, err = comandoFTP(control, 227, "PASV")
+	if err != nil {
+		return nil, err
+	}
+	puerto, err := puertoPASV(mensaje)
+	if err != nil {
+		return nil, err
+	}
+	// Se usa la IP del canal de control: muchos servidores detras de NAT anuncian una IP privada
+	datos, err := vp.Conectar(ctx, "tcp", net.JoinHostPort(u.Hostname(), strconv.Itoa(puerto)))
+	if err != nil {
+		return nil, err
+	}
+	defer datos.Close()
+	datos.SetDeadline(deadline)
+
+	if _, _, err := comandoFTP(control, 1, "RETR %s", ruta); err != nil {
+		return nil, err
+	}
+	cuerpo, err := leerDescarga(datos)
+	if err != nil {
+		return nil, err
+	}
+	datos.Close()
+	if _, _, err := control.ReadResponse(2); err != nil {
+		return nil, err
+	}
+	control.Cmd("QUIT")
+	return strings.Split(string(cuerpo), "\n"), nil
+}
+
+func comandoFTP(control *textproto.Conn, esperado int, formato string, argumentos ...interface{}) (int, string, error) {
+	if _, err := control.Cmd(formato, argumentos...); err != nil {
+		return 0, "", err
+	}
+	codigo, mensaje, err := control.ReadResponse(esperado)
+	if err != nil {
+		return codigo, mensaje, fmt.Errorf("ftp: %v", err)
+	}
+	return codigo, mensaje, nil
+}
+
+// Puerto de datos de una respuesta "227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)"
+func puertoPASV(mensaje string) (int, error) {
+	inicio, fin := strings.Index(mensaje, "("), strings.LastIndex(mensaje, ")")
+	if inicio < 0 || fin < inicio {
+		return 0, fmt.Errorf("ftp: respuesta PASV invalida %q", mensaje)
+	}
+	partes := strings.Split(mensaje[inicio+1:fin], ",")
+	if len(partes) != 6 {
+		return 0, fmt.Errorf("ftp: respuesta PASV invalida %q", mensaje)
+	}
+	alto, err1 := strconv.Atoi(strings.TrimSpace(partes[4]))
+	bajo, err2 := strconv.Atoi(strings.TrimSpace(partes[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("ftp: respuesta PASV invalida %q", mensaje)
+	}
+	return alto<<8 | bajo, nil
+}
+
+// Descarga un archivo por SFTP con el cliente sftp de OpenSSH. Solo admite
+// autenticacion por clave (identity_file o el agente/claves por defecto),
+// porque sftp no acepta claves de usuario por linea de comandos
+func (vp *VerificadorProxies) LeerSFTP(ctx context.Context, direccion string, fuente Fuente) ([]string, error) {
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return nil, err
+	}
+	usuario, clave := credencialesFuente(u, fuente)
+	if clave != "" {
+		return nil, fmt.Errorf("sftp: la autenticacion por clave de usuario no esta soportada, usa identity_file")
+	}
+
+	ruta, err := rutaLoteSFTP(u.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	argumentos := []string{"-q", "-b", "-", "-o", "BatchMode=yes", "-o", "ConnectTimeout=30"}
+	if u.Port() != "" {
+		argumentos = append(argumentos, "-P", u.Port())
+	}
+	if fuente.ArchivoIdentidad != "" {
+		argumentos = append(argumentos, "-i", fuente.ArchivoIdentidad)
+	}
+	// Un usuario o host que empiece con '-' se leeria como opcion de ssh
+	// (por ejemplo -oProxyCommand=...)
+	if strings.HasPrefix(usuario, "-") || strings.HasPrefix(u.Hostname(), "-") {
+		return nil, fmt.Errorf("sftp: el usuario y el host no pueden empezar con '-'")
+	}
+	destino := u.Hostname()
+	if usuario != "" {
+		destino = usuario + "@" + destino
+	}
+	argumentos = append(argumentos, "--", destino)
+
+	ctx, cancelar := context.WithTimeout(ctx, tiempoMaximoTransferencia)
+	defer cancelar()
+	comando := exec.CommandContext(ctx, "sftp", argumentos...)
+	// "@" evita que sftp repita el comando en la salida
+	comando.Stdin = strings.NewReader("@get " + ruta + " /dev/stdout\n")
+	var errores bytes.Buffer
+	comando.Stderr = &errores
+	salida, err := comando.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %v: %s", err, strings.TrimSpace(errores.String()))
+	}
+	return strings.Split(string(salida), "\n"), nil
+}
+
+// Ruta entre comillas dobles para un comando del modo -b de sftp. sftp no usa
+// las reglas de Go: dentro de las comillas interpreta \ y expande *, ? y [ como
+// glob, asi que se rechazan esos caracteres, las comillas y todo lo que no sea
+// ASCII imprimible en lugar de intentar escaparlos
+func rutaLoteSFTP(ruta string) (string, error) {
+	if ruta == "" || ruta == "/" {
+		return "", fmt.Errorf("sftp: falta la ruta del archivo")
+	}
+	for _, r := range ruta {
+		if r < 0x20 || r > 0x7e || strings.ContainsRune(`"\*?[]`, r) {
+			return "", fmt.Errorf("sftp: la ruta %q tiene caracteres no soportados", ruta)
+		}
+	}
+	return `"` + ruta + `"`, nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Fuente de urls.json: una URL sola o un objeto con opciones por fuente
+type Fuente struct {
+	URL            string `json:"url"`
+	Incremental    bool   `json:"incremental"` // fuente de solo agregado: se piden solo los bytes nuevos
+	ParametroDesde string `json:"since_param"` // parametro de query con la hora (unix) de la lectura anterior
+	Tipo           string `json:"type"`        // "feed" para RSS/Atom, "webshare", "proxyscrape" o "api" para proveedores; vacio para texto plano
+	Rastrear       bool   `json:"crawl"`       // la URL es un indice o sitemap: se siguen sus enlaces del mismo host
+	Profundidad    int    `json:"depth"`       // niveles de enlaces que se siguen al rastrear (default 1, maximo 3)
+	Navegador      bool   `json:"headless"`    // si la descarga falla o devuelve un desafio anti-bot se reintenta con -chrome
+
+	// Credenciales para ftp:// y sftp:// cuando no van en la URL
+	Usuario          string `json:"username"`
+	Clave            string `json:"password"`
+	ArchivoIdentidad string `json:"identity_file"` // clave privada SSH para sftp://
+
+	// Proveedores con API ("webshare", "proxyscrape", "api")
+	ClaveAPI         string `json:"api_key"`
+	VariableClaveAPI string `json:"api_key_env"`    // variable de entorno con la clave, para no dejarla en urls.json
+	CabeceraClaveAPI string `json:"api_key_header"` // cabecera con la clave para "api" (default Authorization: Bearer)
+}
+
+func (f *Fuente) UnmarshalJSON(datos []byte) error {
+	var direccion string
+	if err := json.Unmarshal(datos, &direccion); err == nil {
+		*f = Fuente{URL: direccion}
+		return nil
+	}
+	type fuenteJSON Fuente
+	if err := json.Unmarshal(datos, (*fuenteJSON)(f)); err != nil {
+		return err
+	}
+	if f.URL == "" {
+		return errors.New("fuente sin url")
+	}
+	switch f.Tipo {
+	case "", "feed", "webshare", "proxyscrape", "api":
+	default:
+		return fmt.Errorf("tipo de fuente desconocido %q", f.Tipo)
+	}
+	return nil
+}
+
+// Lleva adelante un cursor por fuente
+func (f Fuente) usaCursor() bool {
+	return f.Incremental || f.ParametroDesde != ""
+}
+
+// Comprueba si un host coincide con un patron: "example.com" coincide con el
+// host y sus subdominios, "*.example.com" solo con los subdominios
+func coincideHost(host, patron string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	patron = strings.ToLower(strings.TrimSpace(patron))
+	if strings.HasPrefix(patron, "*.") {
+		return strings.HasSuffix(host, patron[1:])
+	}
+	return host == patron || strings.HasSuffix(host, "."+patron)
+}
+
+// Indica si se permite obtener proxies desde el host segun -allow-hosts y -deny-hosts
+func (vp *VerificadorProxies) HostPermitido(host string) bool {
+	for _, patron := range vp.HostsDenegados {
+		if coincideHost(host, patron) {
+			return false
+		}
+	}
+	if len(vp.HostsPermitidos) == 0 {
+		return true
+	}
+	for _, patron := range vp.HostsPermitidos {
+		if coincideHost(host, patron) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifica que la URL de una fuente apunte a un host permitido
+func (vp *VerificadorProxies) FuentePermitida(direccion string) error {
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return err
+	}
+	if !vp.HostPermitido(u.Hostname()) {
+		return fmt.Errorf("host %s no permitido", u.Hostname())
+	}
+	return nil
+}
+
+// Cliente HTTP para las fuentes que tampoco sigue redirecciones a hosts no permitidos
+func (vp *VerificadorProxies) ClienteFuentes() *http.Client {
+	transporte := http.DefaultTransport.(*http.Transport).Clone()
+	transporte.DialContext = vp.Conectar
+	return &http.Client{
+		Transport: transporte,
+		CheckRedirect: func(solicitud *http.Request, anteriores []*http.Request) error {
+			if len(anteriores) >= 10 {
+				return errors.New("demasiadas redirecciones")
+			}
+			if !vp.HostPermitido(solicitud.URL.Hostname()) {
+				return fmt.Errorf("redireccion a host no permitido %s", solicitud.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
+
+// Descarga una fuente y devuelve sus lineas. En fuentes incrementales solo
+// devuelve lo agregado desde la lectura anterior segun el cursor guardado
+func (vp *VerificadorProxies) LeerFuente(ctx context.Context, cliente *http.Client, direccion string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(direccion, "ftp://"):
+		return vp.LeerFTP(ctx, direccion, vp.OpcionesFuentes[direccion])
+	case strings.HasPrefix(direccion, "sftp://"):
+		return vp.LeerSFTP(ctx, direccion, vp.OpcionesFuentes[direccion])
+	}
+	if lineas, esPaste, err := vp.LeerPastes(ctx, cliente, direccion); esPaste {
+		return lineas, err
+	}
+
+	fuente := vp.OpcionesFuentes[direccion]
+	switch fuente.Tipo {
+	case "feed":
+		return vp.LeerFeed(ctx, cliente, direccion)
+	case "webshare", "proxyscrape", "api":
+		return vp.LeerProveedor(ctx, cliente, direccion, fuente)
+	}
+	if fuente.Rastrear {
+		return vp.Rastrear(ctx, cliente, direccion, fuente.Profundidad)
+	}
+
+	lineas, err := vp.leerTextoFuente(ctx, cliente, direccion, fuente)
+	if fuente.Navegador && vp.Navegador != "" && (err != nil || EsDesafioAntiBot(lineas)) {
+		vp.Log("INFO", fmt.Sprintf("Leyendo %s con el navegador headless", direccion))
+		return vp.LeerConNavegador(ctx, direccion)
+	}
+	return lineas, err
+}
+
+// Lee una fuente de texto plano por HTTP, llevando el cursor si es incremental
+func (vp *VerificadorProxies) leerTextoFuente(ctx context.Context, cliente *http.Client, direccion string, fuente Fuente) ([]string, error) {
+	incremental := fuente.usaCursor() && vp.Cursores != nil
+
+	var cursor Cursor
+	destino := URLRawPaste(direccion)
+	if incremental {
+		cursor = vp.Cursores.Obtener(direccion)
+		destino = cursor.URLConDesde(destino, fuente)
+	}
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, destino, nil)
+	if err != nil {
+		return nil, err
+	}
+	if incremental {
+		cursor.PrepararSolicitud(solicitud, fuente)
+	}
+	inicio := time.Now()
+
+	resp, err := cliente.Do(solicitud)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent, http.StatusNotModified, http.StatusRequestedRangeNotSatisfiable:
+		if !incremental {
+			return nil, fmt.Errorf("estado %d", resp.StatusCode)
+		}
+	default:
+		return nil, fmt.Errorf("estado %d", resp.StatusCode)
+	}
+
+	cuerpo, err := leerDescarga(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !incremental {
+		return LineasDeArchivo(cuerpo)
+	}
+	if cuerpo, err = cursor.Avanzar(resp, cuerpo, fuente, inicio); err != nil {
+		// La fuente cambio de forma incompatible: el proximo intento la lee completa
+		vp.Cursores.Actualizar(direccion, Cursor{})
+		return nil, err
+	}
+	// Solo se devuelve lo agregado desde la lectura anterior
+	var nuevas []string
+	if len(cuerpo) > 0 {
+		if nuevas, err = LineasDeArchivo(cuerpo); err != nil {
+			return nil, err
+		}
+	}
+	vp.Cursores.Actualizar(direccion, cursor)
+	return nuevas, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type rangoPais struct {
+	rangoIPv4
+	Pais string
+}
+
+// Base IP -> pais cargada desde un CSV de rangos IPv4 (db-ip lite o IP2Location LITE DB1)
+type BaseGeoIP struct {
+	rangos []rangoPais
+}
+
+// Convierte una columna de rango en numero; acepta "1.2.3.4" o "16909060"
+func parsearExtremoRango(valor string) (uint32, bool) {
+	valor = strings.TrimSpace(valor)
+	if numero, err := strconv.ParseUint(valor, 10, 32); err == nil {
+		return uint32(numero), true
+	}
+	return ipv4ANumero(net.ParseIP(valor))
+}
+
+// Carga un CSV con columnas inicio,fin,pais[,...]
+func CargarBaseGeoIP(ruta string) (*BaseGeoIP, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, err
+	}
+	defer archivo.Close()
+
+	lector := csv.NewReader(archivo)
+	lector.FieldsPerRecord = -1
+	base := &BaseGeoIP{}
+	for {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ruta, err)
+		}
+		if len(registro) < 3 {
+			continue
+		}
+		inicio, ok1 := parsearExtremoRango(registro[0])
+		fin, ok2 := parsearExtremoRango(registro[1])
+		pais := strings.ToUpper(strings.TrimSpace(registro[2]))
+		if !ok1 || !ok2 || len(pais) != 2 || pais == "--" || pais == "ZZ" {
+			continue
+		}
+		base.rangos = append(base.rangos, rangoPais{rangoIPv4{inicio, fin}, pais})
+	}
+
+	sort.Slice(base.rangos, func(i, j int) bool { return base.rangos[i].Inicio < base.rangos[j].Inicio })
+	return base, nil
+}
+
+// Pais de la IP de un proxy ip:puerto, o "" si no se conoce
+func (bg *BaseGeoIP) Pais(proxy string) string {
+	host, _, err := net.SplitHostPort(DireccionProxy(proxy))
+	if err != nil {
+		host = proxy
+	}
+	numero, ok := ipv4ANumero(net.ParseIP(host))
+	if !ok {
+		return ""
+	}
+	i := sort.Search(len(bg.rangos), func(i int) bool { return bg.rangos[i].Inicio > numero })
+	if i > 0 && bg.rangos[i-1].Fin >= numero {
+		return bg.rangos[i-1].Pais
+	}
+	return ""
+}
+
+// Descarta antes de conectar los proxies ubicados en paises de -deny-countries
+func (vp *VerificadorProxies) FiltrarPaisesDenegados(tipoProxy string, proxies []string) []string {
+	if vp.GeoIP == nil || len(vp.PaisesDenegados) == 0 {
+		return proxies
+	}
+
+	denegados := make(map[string]bool)
+	for _, pais := range vp.PaisesDenegados {
+		denegados[strings.ToUpper(pais)] = true
+	}
+
+	// Sin pais conocido (IPv6, hosts o IPs fuera de la base) no se puede
+	// descartar que este en un pais denegado, asi que tampoco se contacta
+	var permitidos []string
+	desconocidos := 0
+	for _, proxy := range proxies {
+		pais := vp.GeoIP.Pais(proxy)
+		if pais == "" {
+			desconocidos++
+		} else if !denegados[pais] {
+			permitidos = append(permitidos, proxy)
+		}
+	}
+	if descartados := len(proxies) - len(permitidos) - desconocidos; descartados > 0 {
+		vp.Log("INFO", fmt.Sprintf("%d proxies %s no se verifican por estar en paises denegados (%s)", descartados, tipoProxy, strings.Join(vp.PaisesDenegados, ",")))
+	}
+	if desconocidos > 0 {
+		vp.Log("INFO", fmt.Sprintf("%d proxies %s no se verifican por no tener pais conocido en -geoip-db", desconocidos, tipoProxy))
+	}
+	return permitidos
+}
@@ -0,0 +1,3 @@
+module github.com/lilsheepyy/proxy-scrapper-checker
+
+go 1.19
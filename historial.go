@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Una verificacion en el historial (-history): la misma linea que la salida
+// NDJSON mas el resultado, porque aca tambien se guardan los que fallaron
+type RegistroHistorial struct {
+	RegistroNDJSON
+	Funcional bool `json:"working"`
+}
+
+// Agrega al historial una linea por cada resultado
+func (vp *VerificadorProxies) GuardarHistorial(resultados []Resultado, metadatos map[string]MetadatosFuente) error {
+	os.MkdirAll(filepath.Dir(vp.ArchivoHistorial), os.ModePerm)
+	archivo, err := os.OpenFile(vp.ArchivoHistorial, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	escritor := bufio.NewWriter(archivo)
+	for _, resultado := range resultados {
+		datos, err := json.Marshal(RegistroHistorial{
+			RegistroNDJSON: vp.RegistroNDJSON(resultado, metadatos),
+			Funcional:      resultado.Funcional,
+		})
+		if err != nil {
+			archivo.Close()
+			return err
+		}
+		escritor.Write(datos)
+		escritor.WriteByte('\n')
+	}
+	if err := escritor.Flush(); err != nil {
+		archivo.Close()
+		return err
+	}
+	return archivo.Close()
+}
+
+// Columnas de RegistroHistorial.FilaCSV, en orden
+var ColumnasCSV = []string{
+	"run_id", "timestamp", "type", "address", "working", "latency_ms", "error", "country", "rdns",
+	"status", "tls", "failed_assertion", "first_seen", "source_country", "source_anonymity", "denylists",
+}
+
+// El registro como fila CSV con las columnas de ColumnasCSV
+func (rh RegistroHistorial) FilaCSV() []string {
+	metadatos := rh.Metadatos
+	var primeraVez, paisFuente, anonimatoFuente string
+	if metadatos.PrimeraVez != nil {
+		primeraVez = metadatos.PrimeraVez.Format(time.RFC3339)
+	}
+	if metadatos.Fuente != nil {
+		paisFuente, anonimatoFuente = metadatos.Fuente.Pais, metadatos.Fuente.Anonimato
+	}
+	return []string{
+		rh.IDEjecucion,
+		rh.Fecha.Format(time.RFC3339),
+		rh.Tipo,
+		rh.Direccion,
+		strconv.FormatBool(rh.Funcional),
+		strconv.FormatInt(rh.Latencia, 10),
+		metadatos.ClaseError,
+		metadatos.Pais,
+		metadatos.RDNS,
+		metadatos.Estado,
+		metadatos.TLS,
+		metadatos.AsercionFallida,
+		primeraVez,
+		paisFuente,
+		anonimatoFuente,
+		strings.Join(metadatos.Listas, ";"),
+	}
+}
+
+// Convierte el historial a un CSV con una fila por verificacion, para analizarlo
+// con pandas o duckdb. Devuelve la cantidad de filas escritas
+func ExportarHistorial(rutaHistorial, rutaSalida, formato string) (int, error) {
+	if formato != "csv" {
+		return 0, fmt.Errorf("formato %q no soportado; solo csv (parquet necesitaria dependencias externas, se puede convertir el csv con duckdb)", formato)
+	}
+
+	entrada, err := os.Open(rutaHistorial)
+	if err != nil {
+		return 0, err
+	}
+	defer entrada.Close()
+
+	salida, err := os.Create(rutaSalida)
+	if err != nil {
+		return 0, err
+	}
+	escritor := csv.NewWriter(salida)
+	escritor.Write(ColumnasCSV)
+
+	filas := 0
+	lector := bufio.NewScanner(entrada)
+	lector.Buffer(make([]byte, 64*1024), 1024*1024)
+	for numero := 1; lector.Scan(); numero++ {
+		if strings.TrimSpace(lector.Text()) == "" {
+			continue
+		}
+		var registro RegistroHistorial
+		if err := json.Unmarshal(lector.Bytes(), &registro); err != nil {
+			salida.Close()
+			return filas, fmt.Errorf("%s:%d: %v", rutaHistorial, numero, err)
+		}
+		escritor.Write(registro.FilaCSV())
+		filas++
+	}
+	if err := lector.Err(); err != nil {
+		salida.Close()
+		return filas, err
+	}
+
+	escritor.Flush()
+	if err := escritor.Error(); err != nil {
+		salida.Close()
+		return filas, err
+	}
+	return filas, salida.Close()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Formatos de -import-format
+var FormatosImportacion = []string{"auto", "proxybroker", "typed", "nmap"}
+
+// Lee un archivo con la salida de otra herramienta y devuelve sus proxies por
+// tipo, como lineas de fuente ("ip:puerto PAIS anonimato") para que pasen por
+// la misma sanitizacion, metadatos y verificacion que lo descargado
+func ImportarArchivo(ruta, formato string) (map[string][]string, error) {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, err
+	}
+	if formato == "" || formato == "auto" {
+		formato = DetectarFormatoImportacion(datos)
+	}
+	switch formato {
+	case "proxybroker":
+		return importarProxyBroker(datos)
+	case "typed":
+		return importarTipado(datos), nil
+	case "nmap":
+		return importarNmap(datos)
+	default:
+		return nil, fmt.Errorf("formato de importacion desconocido %q, opciones: %s", formato, strings.Join(FormatosImportacion, ", "))
+	}
+}
+
+// Formato segun el primer caracter util: JSON de ProxyBroker, XML de nmap o
+// texto "tipo pais ip:puerto"
+func DetectarFormatoImportacion(datos []byte) string {
+	datos = bytes.TrimSpace(bytes.TrimPrefix(datos, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(datos, []byte("[")), bytes.HasPrefix(datos, []byte("{")):
+		return "proxybroker"
+	case bytes.HasPrefix(datos, []byte("<")):
+		return "nmap"
+	default:
+		return "typed"
+	}
+}
+
+// Tipo de este programa para un nombre de protocolo de otra herramienta, "" si
+// no corresponde a ninguno
+func tipoImportado(nombre string) string {
+	nombre = strings.ToLower(nombre)
+	switch {
+	case strings.Contains(nombre, "socks5"):
+		return "socks5"
+	case strings.Contains(nombre, "socks4"):
+		return "socks4"
+	case nombre == "http", nombre == "https", strings.HasPrefix(nombre, "connect"),
+		nombre == "http-proxy", nombre == "squid-http", nombre == "polipo":
+		return "http"
+	default:
+		return ""
+	}
+}
+
+// Un proxy de `proxybroker find --format json`
+type proxyProxyBroker struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	Geo  struct {
+		Pais struct {
+			Codigo string `json:"code"`
+		} `json:"country"`
+	} `json:"geo"`
+	Tipos []struct {
+		Tipo  string `json:"type"`
+		Nivel string `json:"level"`
+	} `json:"types"`
+}
+
+// ProxyBroker escribe un arreglo JSON o un objeto por linea segun la version
+func importarProxyBroker(datos []byte) (map[string][]string, error) {
+	var proxies []proxyProxyBroker
+	decodificador := json.NewDecoder(bytes.NewReader(datos))
+	if bytes.HasPrefix(bytes.TrimSpace(datos), []byte("[")) {
+		if err := decodificador.Decode(&proxies); err != nil {
+			return nil, fmt.Errorf("proxybroker: %v", err)
+		}
+	} else {
+		for {
+			var proxy proxyProxyBroker
+			err := decodificador.Decode(&proxy)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("proxybroker: %v", err)
+			}
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	porTipo := make(map[string][]string)
+	for _, proxy := range proxies {
+		if proxy.Host == "" || proxy.Port <= 0 {
+			continue
+		}
+		vistos := make(map[string]bool)
+		for _, tipo := range proxy.Tipos {
+			tipoProxy := tipoImportado(tipo.Tipo)
+			if tipoProxy == "" || vistos[tipoProxy] {
+				continue
+			}
+			vistos[tipoProxy] = true
+			campos := []string{net.JoinHostPort(proxy.Host, strconv.Itoa(proxy.Port))}
+			if proxy.Geo.Pais.Codigo != "" && proxy.Geo.Pais.Codigo != "--" {
+				campos = append(campos, proxy.Geo.Pais.Codigo)
+			}
+			if tipo.Nivel != "" {
+				campos = append(campos, strings.ToLower(tipo.Nivel))
+			}
+			porTipo[tipoProxy] = append(porTipo[tipoProxy], strings.Join(campos, " "))
+		}
+	}
+	return porTipo, nil
+}
+
+// Lineas "tipo [pais] ip:puerto" de las listas de texto de otras herramientas;
+// se ignoran comentarios y lineas sin un tipo conocido al principio
+func importarTipado(datos []byte) map[string][]string {
+	porTipo := make(map[string][]string)
+	for _, linea := range strings.Split(string(datos), "\n") {
+		campos := strings.Fields(linea)
+		if len(campos) < 2 || strings.HasPrefix(campos[0], "#") {
+			continue
+		}
+		tipoProxy := tipoImportado(strings.TrimSuffix(campos[0], ":"))
+		if tipoProxy == "" {
+			continue
+		}
+		// La direccion va al final; lo del medio (pais) queda como metadato
+		resto := append([]string{campos[len(campos)-1]}, campos[1:len(campos)-1]...)
+		porTipo[tipoProxy] = append(porTipo[tipoProxy], strings.Join(resto, " "))
+	}
+	return porTipo
+}
+
+// Salida XML de nmap (-oX)
+type reporteNmap struct {
+	Hosts []struct {
+		Direcciones []struct {
+			Direccion string `xml:"addr,attr"`
+			Tipo      string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Puertos []struct {
+			Numero int `xml:"portid,attr"`
+			Estado struct {
+				Estado string `xml:"state,attr"`
+			} `xml:"state"`
+			Servicio struct {
+				Nombre string `xml:"name,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// Puertos abiertos cuyo servicio nmap identifico como proxy
+func importarNmap(datos []byte) (map[string][]string, error) {
+	var reporte reporteNmap
+	if err := xml.Unmarshal(datos, &reporte); err != nil {
+		return nil, fmt.Errorf("nmap: %v", err)
+	}
+
+	porTipo := make(map[string][]string)
+	for _, host := range reporte.Hosts {
+		var ip string
+		for _, direccion := range host.Direcciones {
+			if direccion.Tipo == "ipv4" || direccion.Tipo == "ipv6" {
+				ip = direccion.Direccion
+				break
+			}
+		}
+		if ip == "" {
+			continue
+		}
+		for _, puerto := range host.Puertos {
+			if puerto.Estado.Estado != "open" {
+				continue
+			}
+			if tipoProxy := tipoImportado(puerto.Servicio.Nombre); tipoProxy != "" {
+				porTipo[tipoProxy] = append(porTipo[tipoProxy], net.JoinHostPort(ip, strconv.Itoa(puerto.Numero)))
+			}
+		}
+	}
+	return porTipo, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bytes del inicio de la fuente con los que se detecta si fue reescrita
+const largoMaximoCabecera = 4096
+
+// Posicion alcanzada en una fuente incremental o enlaces ya seguidos de un feed
+type Cursor struct {
+	Desplazamiento     int64     `json:"offset"`          // bytes ya procesados, siempre al final de una linea
+	LargoCabecera      int       `json:"head_length"`     // bytes del inicio cubiertos por HuellaCabecera
+	HuellaCabecera     string    `json:"head_sha256"`     // sha256 del inicio para detectar reescrituras
+	ETag               string    `json:"etag"`            // ETag de la ultima respuesta
+	UltimaModificacion string    `json:"last_modified"`   // Last-Modified de la ultima respuesta
+	UltimaLectura      time.Time `json:"last_read"`       // hora en que se pidio la ultima lectura
+	Elementos          []string  `json:"items,omitempty"` // enlaces de feeds ya seguidos
+}
+
+// URL con el parametro since_param si la fuente lo usa y hubo una lectura anterior
+func (c Cursor) URLConDesde(direccion string, fuente Fuente) string {
+	if fuente.ParametroDesde == "" || c.UltimaLectura.IsZero() {
+		return direccion
+	}
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return direccion
+	}
+	query := u.Query()
+	query.Set(fuente.ParametroDesde, strconv.FormatInt(c.UltimaLectura.Unix(), 10))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Agrega Range y las cabeceras condicionales para pedir solo lo nuevo
+func (c Cursor) PrepararSolicitud(solicitud *http.Request, fuente Fuente) {
+	if !fuente.Incremental {
+		return
+	}
+	if c.ETag != "" {
+		solicitud.Header.Set("If-None-Match", c.ETag)
+	} else if c.UltimaModificacion != "" {
+		solicitud.Header.Set("If-Modified-Since", c.UltimaModificacion)
+	}
+	if c.Desplazamiento > 0 {
+		solicitud.Header.Set("Range", fmt.Sprintf("bytes=%d-", c.Desplazamiento))
+		// Si el recurso cambio el servidor manda el contenido completo en lugar del rango
+		if c.ETag != "" {
+			solicitud.Header.Set("If-Range", c.ETag)
+		} else if c.UltimaModificacion != "" {
+			solicitud.Header.Set("If-Range", c.UltimaModificacion)
+		}
+	}
+}
+
+// Devuelve la parte no procesada de la respuesta y mueve el cursor hasta la
+// ultima linea completa. Las lineas a medio escribir quedan para la proxima lectura
+func (c *Cursor) Avanzar(resp *http.Response, cuerpo []byte, fuente Fuente, inicio time.Time) ([]byte, error) {
+	c.UltimaLectura = inicio
+	if !fuente.Incremental {
+		return cuerpo, nil
+	}
+
+	var nuevo []byte
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// "bytes */N" con N igual al desplazamiento: no se agrego nada
+		if resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", c.Desplazamiento) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("rango %d fuera de la fuente", c.Desplazamiento)
+
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", c.Desplazamiento)) {
+			return nil, fmt.Errorf("rango inesperado %q", resp.Header.Get("Content-Range"))
+		}
+		nuevo = cuerpo
+
+	default:
+		// Sin soporte de Range: se descarta lo ya procesado si el inicio no cambio
+		if c.Desplazamiento > 0 && int64(len(cuerpo)) >= c.Desplazamiento && c.LargoCabecera <= len(cuerpo) &&
+			huella(cuerpo[:c.LargoCabecera]) == c.HuellaCabecera {
+			nuevo = cuerpo[c.Desplazamiento:]
+		} else {
+			c.Desplazamiento = 0
+			nuevo = cuerpo
+		}
+	}
+
+	completo := 0
+	for i := len(nuevo) - 1; i >= 0; i-- {
+		if nuevo[i] == '\n' {
+			completo = i + 1
+			break
+		}
+	}
+	if c.Desplazamiento == 0 {
+		c.LargoCabecera = completo
+		if c.LargoCabecera > largoMaximoCabecera {
+			c.LargoCabecera = largoMaximoCabecera
+		}
+		c.HuellaCabecera = huella(nuevo[:c.LargoCabecera])
+	}
+	c.Desplazamiento += int64(completo)
+	c.ETag = resp.Header.Get("ETag")
+	c.UltimaModificacion = resp.Header.Get("Last-Modified")
+	return nuevo[:completo], nil
+}
+
+func huella(datos []byte) string {
+	suma := sha256.Sum256(datos)
+	return hex.EncodeToString(suma[:])
+}
+
+// Cursores de las fuentes incrementales, guardados entre ejecuciones
+type RegistroCursores struct {
+	Ruta     string
+	Cursores map[string]Cursor
+	mu       sync.Mutex
+}
+
+// Carga los cursores desde disco; si el archivo no existe empieza vacio
+func CargarRegistroCursores(ruta string) (*RegistroCursores, error) {
+	rc := &RegistroCursores{Ruta: ruta, Cursores: make(map[string]Cursor)}
+
+	datos, err := os.ReadFile(ruta)
+	if os.IsNotExist(err) {
+		return rc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(datos, &rc.Cursores); err != nil {
+		return nil, fmt.Errorf("%s: %v", ruta, err)
+	}
+	return rc, nil
+}
+
+func (rc *RegistroCursores) Obtener(direccion string) Cursor {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.Cursores[direccion]
+}
+
+func (rc *RegistroCursores) Actualizar(direccion string, cursor Cursor) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.Cursores[direccion] = cursor
+}
+
+// Guarda los cursores en disco
+func (rc *RegistroCursores) Guardar() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.Cursores) == 0 {
+		return nil
+	}
+	datos, err := json.Marshal(rc.Cursores)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(rc.Ruta), os.ModePerm)
+	temporal := rc.Ruta + ".tmp"
+	if err := os.WriteFile(temporal, datos, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporal, rc.Ruta)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func respuestaRango(estado int, rango string) *http.Response {
+	resp := &http.Response{StatusCode: estado, Header: make(http.Header)}
+	if rango != "" {
+		resp.Header.Set("Content-Range", rango)
+	}
+	return resp
+}
+
+func TestCursorAvanzar(t *testing.T) {
+	incremental := Fuente{Incremental: true}
+	casos := []struct {
+		nombre         string
+		fuente         Fuente
+		previo         string // cuerpo de una lectura completa anterior; "" si es la primera
+		estado         int
+		rango          string
+		cuerpo         string
+		esperado       string
+		desplazamiento int64
+		error          bool
+	}{
+		{nombre: "primera lectura", fuente: incremental, estado: 200, cuerpo: "a\nb\nc", esperado: "a\nb\n", desplazamiento: 4},
+		{nombre: "sin lineas completas", fuente: incremental, estado: 200, cuerpo: "a", esperado: "", desplazamiento: 0},
+		{nombre: "rango nuevo", fuente: incremental, previo: "a\nb\n", estado: 206, rango: "bytes 4-9/10", cuerpo: "c\nd\ne", esperado: "c\nd\n", desplazamiento: 8},
+		{nombre: "rango inesperado", fuente: incremental, previo: "a\nb\n", estado: 206, rango: "bytes 0-9/10", cuerpo: "a\nb\nc\nd\ne", error: true, desplazamiento: 4},
+		{nombre: "sin cambios", fuente: incremental, previo: "a\nb\n", estado: 304, esperado: "", desplazamiento: 4},
+		{nombre: "rango al final", fuente: incremental, previo: "a\nb\n", estado: 416, rango: "bytes */4", esperado: "", desplazamiento: 4},
+		{nombre: "fuente truncada", fuente: incremental, previo: "a\nb\n", estado: 416, rango: "bytes */2", error: true, desplazamiento: 4},
+		{nombre: "sin soporte de Range", fuente: incremental, previo: "a\nb\n", estado: 200, cuerpo: "a\nb\nc\n", esperado: "c\n", desplazamiento: 6},
+		{nombre: "fuente reescrita", fuente: incremental, previo: "a\nb\n", estado: 200, cuerpo: "x\ny\nz\n", esperado: "x\ny\nz\n", desplazamiento: 6},
+		{nombre: "no incremental", fuente: Fuente{}, estado: 200, cuerpo: "a\nb", esperado: "a\nb", desplazamiento: 0},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			var cursor Cursor
+			if caso.previo != "" {
+				if _, err := cursor.Avanzar(respuestaRango(200, ""), []byte(caso.previo), caso.fuente, time.Now()); err != nil {
+					t.Fatalf("lectura anterior: %v", err)
+				}
+			}
+			nuevo, err := cursor.Avanzar(respuestaRango(caso.estado, caso.rango), []byte(caso.cuerpo), caso.fuente, time.Now())
+			if caso.error != (err != nil) {
+				t.Fatalf("error = %v, se esperaba error %t", err, caso.error)
+			}
+			if string(nuevo) != caso.esperado {
+				t.Errorf("devolvio %q, se esperaba %q", nuevo, caso.esperado)
+			}
+			if cursor.Desplazamiento != caso.desplazamiento {
+				t.Errorf("desplazamiento %d, se esperaba %d", cursor.Desplazamiento, caso.desplazamiento)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Contexto de un host obtenido de Shodan o Censys
+type InfoHost struct {
+	Fuente       string   `json:"source"`
+	Puertos      []int    `json:"ports,omitempty"`
+	Servicios    []string `json:"services,omitempty"` // "puerto/servicio"
+	Organizacion string   `json:"org,omitempty"`
+	Etiquetas    []string `json:"tags,omitempty"`
+}
+
+var clienteInteligencia = &http.Client{Timeout: 15 * time.Second}
+
+// Servicio de inteligencia consultable por IP
+type consultaInteligencia struct {
+	interruptor *Interruptor
+	consultar   func(ctx context.Context, ip string) (InfoHost, error)
+}
+
+// Lo obtenido para una IP, compartido entre proxies con la misma IP
+type infoIP struct {
+	info      []InfoHost
+	faltantes []string
+}
+
+// Consulta Shodan y/o Censys por la IP de cada proxy funcional y adjunta los
+// puertos y servicios conocidos al resultado. Es best-effort: un servicio que
+// falla seguido se deja de consultar, la etapa se corta al agotar
+// -enrich-timeout y lo que no se pudo consultar queda en Faltantes
+func (vp *VerificadorProxies) EnriquecerConInteligencia(ctx context.Context, resultados []Resultado) {
+	var consultas []consultaInteligencia
+	if vp.ClaveShodan != "" {
+		consultas = append(consultas, consultaInteligencia{&Interruptor{Nombre: "shodan"}, vp.ConsultarShodan})
+	}
+	if vp.CensysID != "" && vp.CensysSecreto != "" {
+		consultas = append(consultas, consultaInteligencia{&Interruptor{Nombre: "censys"}, vp.ConsultarCensys})
+	}
+	if len(consultas) == 0 {
+		return
+	}
+	ctx, cancelar := vp.contextoEnriquecimiento(ctx)
+	defer cancelar()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	tokens := make(chan struct{}, 5)
+	errores := make(map[string]int)
+	cache := make(map[string]infoIP)
+
+	for i := range resultados {
+		if !resultados[i].Funcional {
+			continue
+		}
+		ip, _, err := net.SplitHostPort(DireccionProxy(resultados[i].Proxy))
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(resultado *Resultado, ip string) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			mu.Lock()
+			datosIP, ok := cache[ip]
+			mu.Unlock()
+			if !ok {
+				for _, consulta := range consultas {
+					nombre := consulta.interruptor.Nombre
+					if ctx.Err() != nil || !consulta.interruptor.Permitir() {
+						datosIP.faltantes = append(datosIP.faltantes, nombre)
+						continue
+					}
+					datos, err := consulta.consultar(ctx, ip)
+					if consulta.interruptor.Registrar(err) {
+						vp.Log("WARNING", fmt.Sprintf("%s fallo %d veces seguidas, se pausa %s", nombre, fallosInterruptor, pausaInterruptor))
+					}
+					if err != nil {
+						datosIP.faltantes = append(datosIP.faltantes, nombre)
+						mu.Lock()
+						errores[nombre]++
+						mu.Unlock()
+						continue
+					}
+					datosIP.info = append(datosIP.info, datos)
+				}
+				mu.Lock()
+				cache[ip] = datosIP
+				mu.Unlock()
+			}
+			resultado.Inteligencia = datosIP.info
+			for _, faltante := range datosIP.faltantes {
+				resultado.marcarFaltante(faltante)
+			}
+		}(&resultados[i], ip)
+	}
+	wg.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		vp.Log("WARNING", fmt.Sprintf("Se agoto -enrich-timeout (%s) consultando inteligencia; los proxies restantes quedan sin datos", vp.TimeoutEnriquecimiento))
+	}
+
+	for fuente, cantidad := range errores {
+		vp.Log("WARNING", fmt.Sprintf("%d consultas a %s fallaron", cantidad, fuente))
+	}
+}
+
+func (vp *VerificadorProxies) ConsultarShodan(ctx context.Context, ip string) (InfoHost, error) {
+	info := InfoHost{Fuente: "shodan"}
+	direccion := fmt.Sprintf("https://api.shodan.io/shodan/host/%s?key=%s", ip, url.QueryEscape(vp.ClaveShodan))
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, direccion, nil)
+	if err != nil {
+		return info, err
+	}
+
+	var respuesta struct {
+		Puertos      []int    `json:"ports"`
+		Organizacion string   `json:"org"`
+		Etiquetas    []string `json:"tags"`
+		Datos        []struct {
+			Puerto   int    `json:"port"`
+			Producto string `json:"product"`
+			Shodan   struct {
+				Modulo string `json:"module"`
+			} `json:"_shodan"`
+		} `json:"data"`
+	}
+	if err := consultarJSON(solicitud, &respuesta); err != nil {
+		return info, err
+	}
+
+	info.Puertos = respuesta.Puertos
+	info.Organizacion = respuesta.Organizacion
+	info.Etiquetas = respuesta.Etiquetas
+	for _, dato := range respuesta.Datos {
+		servicio := dato.Shodan.Modulo
+		if dato.Producto != "" {
+			servicio = dato.Producto
+		}
+		info.Servicios = append(info.Servicios, fmt.Sprintf("%d/%s", dato.Puerto, servicio))
+	}
+	sort.Ints(info.Puertos)
+	return info, nil
+}
+
+func (vp *VerificadorProxies) ConsultarCensys(ctx context.Context, ip string) (InfoHost, error) {
+	info := InfoHost{Fuente: "censys"}
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://search.censys.io/api/v2/hosts/"+ip, nil)
+	if err != nil {
+		return info, err
+	}
+	solicitud.SetBasicAuth(vp.CensysID, vp.CensysSecreto)
+
+	var respuesta struct {
+		Resultado struct {
+			Servicios []struct {
+				Puerto int    `json:"port"`
+				Nombre string `json:"service_name"`
+			} `json:"services"`
+			SistemaAutonomo struct {
+				Nombre string `json:"name"`
+			} `json:"autonomous_system"`
+			Etiquetas []string `json:"labels"`
+		} `json:"result"`
+	}
+	if err := consultarJSON(solicitud, &respuesta); err != nil {
+		return info, err
+	}
+
+	info.Organizacion = respuesta.Resultado.SistemaAutonomo.Nombre
+	info.Etiquetas = respuesta.Resultado.Etiquetas
+	for _, servicio := range respuesta.Resultado.Servicios {
+		info.Puertos = append(info.Puertos, servicio.Puerto)
+		info.Servicios = append(info.Servicios, fmt.Sprintf("%d/%s", servicio.Puerto, servicio.Nombre))
+	}
+	sort.Ints(info.Puertos)
+	return info, nil
+}
+
+func consultarJSON(solicitud *http.Request, destino interface{}) error {
+	resp, err := clienteInteligencia.Do(solicitud)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s respondio %s", solicitud.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(destino)
+}
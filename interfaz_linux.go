@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Control para net.Dialer que ata cada socket a la interfaz indicada con
+// SO_BINDTODEVICE, para que todo el trafico salga por ella (por ejemplo wg0)
+func ControlInterfaz(nombre string) (func(red, direccion string, c syscall.RawConn) error, error) {
+	if _, err := net.InterfaceByName(nombre); err != nil {
+		return nil, fmt.Errorf("interfaz %s: %v", nombre, err)
+	}
+	return func(red, direccion string, c syscall.RawConn) error {
+		var errSocket error
+		err := c.Control(func(fd uintptr) {
+			errSocket = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, nombre)
+		})
+		if err != nil {
+			return err
+		}
+		if errSocket != nil {
+			return fmt.Errorf("no se pudo atar el socket a %s: %v", nombre, errSocket)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func ControlInterfaz(nombre string) (func(red, direccion string, c syscall.RawConn) error, error) {
+	return nil, errors.New("-interface solo esta disponible en linux")
+}
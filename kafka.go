@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Publica proxies funcionales en un topic de Kafka a traves de un Kafka REST
+// Proxy (API v2), agrupando registros para no hacer una solicitud por proxy.
+// No es un productor nativo: sin un REST Proxy delante del cluster no funciona
+type SumideroKafka struct {
+	URLRest   string
+	Topico    string
+	cliente   *http.Client
+	registros chan RegistroNDJSON
+	listo     chan struct{}
+	logf      func(nivel, mensaje string)
+}
+
+const (
+	loteMaximoKafka    = 100
+	intervaloLoteKafka = time.Second
+)
+
+func NuevoSumideroKafka(urlRest, topico string, logf func(nivel, mensaje string)) *SumideroKafka {
+	sk := &SumideroKafka{
+		URLRest:   urlRest,
+		Topico:    topico,
+		cliente:   &http.Client{Timeout: 15 * time.Second},
+		registros: make(chan RegistroNDJSON, 1000),
+		listo:     make(chan struct{}),
+		logf:      logf,
+	}
+	go sk.enviarLotes()
+	return sk
+}
+
+// Encola el registro; si la cola esta llena espera a que el envio la vacie o a que se cancele ctx
+func (sk *SumideroKafka) Publicar(ctx context.Context, registro RegistroNDJSON) error {
+	select {
+	case sk.registros <- registro:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Envia lo pendiente y espera a que termine el envio
+func (sk *SumideroKafka) Cerrar() error {
+	close(sk.registros)
+	<-sk.listo
+	return nil
+}
+
+func (sk *SumideroKafka) enviarLotes() {
+	defer close(sk.listo)
+
+	ticker := time.NewTicker(intervaloLoteKafka)
+	defer ticker.Stop()
+
+	var lote []RegistroNDJSON
+	enviar := func() {
+		if len(lote) == 0 {
+			return
+		}
+		if err := sk.enviar(lote); err != nil {
+			sk.logf("ERROR", fmt.Sprintf("No se pudieron publicar %d proxies en Kafka: %v", len(lote), err))
+		}
+		lote = nil
+	}
+
+	for {
+		select {
+		case registro, ok := <-sk.registros:
+			if !ok {
+				enviar()
+				return
+			}
+			lote = append(lote, registro)
+			if len(lote) >= loteMaximoKafka {
+				enviar()
+			}
+		case <-ticker.C:
+			enviar()
+		}
+	}
+}
+
+func (sk *SumideroKafka) enviar(lote []RegistroNDJSON) error {
+	type registroKafka struct {
+		Clave string         `json:"key"`
+		Valor RegistroNDJSON `json:"value"`
+	}
+	cuerpo := struct {
+		Registros []registroKafka `json:"records"`
+	}{}
+	for _, registro := range lote {
+		cuerpo.Registros = append(cuerpo.Registros, registroKafka{Clave: registro.Tipo + "://" + registro.Direccion, Valor: registro})
+	}
+
+	datos, err := json.Marshal(cuerpo)
+	if err != nil {
+		return err
+	}
+	destino := fmt.Sprintf("%s/topics/%s", sk.URLRest, url.PathEscape(sk.Topico))
+	solicitud, err := http.NewRequest(http.MethodPost, destino, bytes.NewReader(datos))
+	if err != nil {
+		return err
+	}
+	solicitud.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	solicitud.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := sk.cliente.Do(solicitud)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("REST proxy respondio %s", resp.Status)
+	}
+
+	// Con 200 el REST Proxy puede haber rechazado registros sueltos: cada
+	// offset trae error_code y error, en el mismo orden que los registros
+	var respuesta struct {
+		Offsets []struct {
+			CodigoError *int   `json:"error_code"`
+			Error       string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respuesta); err != nil {
+		return fmt.Errorf("respuesta del REST proxy invalida: %v", err)
+	}
+	if len(respuesta.Offsets) != len(lote) {
+		return fmt.Errorf("el REST proxy devolvio %d offsets para %d registros", len(respuesta.Offsets), len(lote))
+	}
+	rechazados := 0
+	for i, offset := range respuesta.Offsets {
+		if offset.CodigoError == nil {
+			continue
+		}
+		rechazados++
+		sk.logf("ERROR", fmt.Sprintf("Kafka rechazo %s://%s: %s (error_code %d)", lote[i].Tipo, lote[i].Direccion, offset.Error, *offset.CodigoError))
+	}
+	if rechazados > 0 {
+		sk.logf("WARNING", fmt.Sprintf("Kafka publico %d de %d proxies del lote", len(lote)-rechazados, len(lote)))
+	}
+	return nil
+}
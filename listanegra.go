@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rango de IPv4 [Inicio, Fin]
+type rangoIPv4 struct {
+	Inicio uint32
+	Fin    uint32
+}
+
+// Lista de redes de una fuente de reputacion (Spamhaus DROP, FireHOL...)
+type ListaNegra struct {
+	Nombre string
+	rangos []rangoIPv4
+}
+
+// Conjunto de listas negras consultadas al sanitizar
+type ListasNegras struct {
+	Listas    []*ListaNegra
+	Etiquetar bool // etiqueta los proxies en lugar de excluirlos
+}
+
+func ipv4ANumero(ip net.IP) (uint32, bool) {
+	ip = ip.To4()
+	if ip == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ip), true
+}
+
+// Lee una lista de IPs/CIDR ignorando comentarios con ';' o '#'
+func ParsearListaNegra(nombre string, lector io.Reader) (*ListaNegra, error) {
+	lista := &ListaNegra{Nombre: nombre}
+	scanner := bufio.NewScanner(lector)
+	for scanner.Scan() {
+		linea := scanner.Text()
+		if i := strings.IndexAny(linea, ";#"); i >= 0 {
+			linea = linea[:i]
+		}
+		campos := strings.Fields(linea)
+		if len(campos) == 0 {
+			continue
+		}
+
+		entrada := campos[0]
+		if !strings.Contains(entrada, "/") {
+			entrada += "/32"
+		}
+		_, red, err := net.ParseCIDR(entrada)
+		if err != nil {
+			continue
+		}
+		inicio, ok := ipv4ANumero(red.IP)
+		if !ok {
+			continue
+		}
+		unos, bits := red.Mask.Size()
+		lista.rangos = append(lista.rangos, rangoIPv4{Inicio: inicio, Fin: inicio | (1<<uint(bits-unos) - 1)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ordena y une rangos superpuestos para buscar con busqueda binaria
+	sort.Slice(lista.rangos, func(i, j int) bool { return lista.rangos[i].Inicio < lista.rangos[j].Inicio })
+	var unidos []rangoIPv4
+	for _, rango := range lista.rangos {
+		if n := len(unidos); n > 0 && rango.Inicio <= unidos[n-1].Fin {
+			if rango.Fin > unidos[n-1].Fin {
+				unidos[n-1].Fin = rango.Fin
+			}
+			continue
+		}
+		unidos = append(unidos, rango)
+	}
+	lista.rangos = unidos
+	return lista, nil
+}
+
+// Indica si la IP esta dentro de alguna red de la lista
+func (ln *ListaNegra) Contiene(ip net.IP) bool {
+	numero, ok := ipv4ANumero(ip)
+	if !ok {
+		return false
+	}
+	i := sort.Search(len(ln.rangos), func(i int) bool { return ln.rangos[i].Inicio > numero })
+	return i > 0 && ln.rangos[i-1].Fin >= numero
+}
+
+// Nombres de las listas en las que aparece la IP de un proxy ip:puerto
+func (lns *ListasNegras) Buscar(proxy string) []string {
+	host, _, err := net.SplitHostPort(DireccionProxy(proxy))
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	var encontradas []string
+	for _, lista := range lns.Listas {
+		if lista.Contiene(ip) {
+			encontradas = append(encontradas, lista.Nombre)
+		}
+	}
+	return encontradas
+}
+
+// Excluye los proxies listados, o solo los cuenta si se pidio etiquetar
+func (vp *VerificadorProxies) FiltrarListasNegras(tipoProxy string, proxies []string) []string {
+	if vp.ListasNegras == nil {
+		return proxies
+	}
+
+	var limpios []string
+	listados := 0
+	for _, proxy := range proxies {
+		if len(vp.ListasNegras.Buscar(proxy)) > 0 {
+			listados++
+			if !vp.ListasNegras.Etiquetar {
+				continue
+			}
+		}
+		limpios = append(limpios, proxy)
+	}
+
+	if listados > 0 {
+		if vp.ListasNegras.Etiquetar {
+			vp.Log("INFO", fmt.Sprintf("%d proxies %s aparecen en listas negras (etiquetados)", listados, tipoProxy))
+		} else {
+			vp.Log("INFO", fmt.Sprintf("%d proxies %s excluidos por listas negras", listados, tipoProxy))
+		}
+	}
+	return limpios
+}
+
+// Descarga las listas negras si la copia local es mas vieja que refresco
+func CargarListasNegras(urls []string, dirCache string, refresco time.Duration, logf func(nivel, mensaje string)) *ListasNegras {
+	os.MkdirAll(dirCache, os.ModePerm)
+	listas := &ListasNegras{}
+	for _, direccion := range urls {
+		u, err := url.Parse(direccion)
+		if err != nil {
+			logf("ERROR", fmt.Sprintf("URL de lista negra invalida %s: %v", direccion, err))
+			continue
+		}
+		nombre := u.Hostname() + strings.ReplaceAll(u.Path, "/", "_")
+		rutaCache := filepath.Join(dirCache, nombre)
+
+		if info, err := os.Stat(rutaCache); err != nil || time.Since(info.ModTime()) > refresco {
+			if err := descargarArchivo(direccion, rutaCache); err != nil {
+				logf("WARNING", fmt.Sprintf("No se pudo actualizar la lista negra %s: %v", direccion, err))
+			} else {
+				logf("INFO", fmt.Sprintf("Lista negra %s actualizada", direccion))
+			}
+		}
+
+		archivo, err := os.Open(rutaCache)
+		if err != nil {
+			logf("ERROR", fmt.Sprintf("Lista negra %s no disponible: %v", direccion, err))
+			continue
+		}
+		lista, err := ParsearListaNegra(nombre, archivo)
+		archivo.Close()
+		if err != nil {
+			logf("ERROR", fmt.Sprintf("No se pudo leer la lista negra %s: %v", rutaCache, err))
+			continue
+		}
+		logf("INFO", fmt.Sprintf("Lista negra %s: %d rangos", nombre, len(lista.rangos)))
+		listas.Listas = append(listas.Listas, lista)
+	}
+	return listas
+}
+
+func descargarArchivo(direccion, ruta string) error {
+	cliente := &http.Client{Timeout: 60 * time.Second}
+	resp, err := cliente.Get(direccion)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("respuesta %s", resp.Status)
+	}
+
+	temporal := ruta + ".tmp"
+	archivo, err := os.Create(temporal)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(archivo, resp.Body); err != nil {
+		archivo.Close()
+		os.Remove(temporal)
+		return err
+	}
+	if err := archivo.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporal, ruta)
+}
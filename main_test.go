@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCodigoSalida(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		resumen   ResumenEjecucion
+		cancelado bool
+		minimo    int
+		esperado  int
+	}{
+		{nombre: "sin -check", resumen: ResumenEjecucion{FuentesObtenidas: 2, Funcionales: map[string]int{"http": 10}}, esperado: SalidaOK},
+		{nombre: "con funcionales", resumen: ResumenEjecucion{Verificado: true, FuentesObtenidas: 1, Funcionales: map[string]int{"http": 3, "socks5": 0}}, esperado: SalidaOK},
+		{nombre: "cancelado", resumen: ResumenEjecucion{Verificado: true}, cancelado: true, esperado: SalidaCancelado},
+		{nombre: "sin fuentes", resumen: ResumenEjecucion{FuentesIntentadas: 3}, esperado: SalidaSinFuentes},
+		{nombre: "solo -import", resumen: ResumenEjecucion{Importados: 5, Funcionales: map[string]int{"http": 5}}, esperado: SalidaOK},
+		{nombre: "sin funcionales", resumen: ResumenEjecucion{Verificado: true, FuentesObtenidas: 1, Funcionales: map[string]int{"http": 0}}, esperado: SalidaSinFuncionales},
+		{nombre: "sin funcionales tras -import", resumen: ResumenEjecucion{Verificado: true, Importados: 5}, esperado: SalidaSinFuncionales},
+		{nombre: "bajo el minimo", resumen: ResumenEjecucion{Verificado: true, FuentesObtenidas: 1, Funcionales: map[string]int{"http": 3, "socks5": 20}}, minimo: 10, esperado: SalidaBajoMinimo},
+		{nombre: "sobre el minimo", resumen: ResumenEjecucion{Verificado: true, FuentesObtenidas: 1, Funcionales: map[string]int{"http": 10}}, minimo: 10, esperado: SalidaOK},
+	}
+	for _, caso := range casos {
+		if codigo := caso.resumen.CodigoSalida(caso.cancelado, caso.minimo); codigo != caso.esperado {
+			t.Errorf("%s: CodigoSalida = %d, se esperaba %d", caso.nombre, codigo, caso.esperado)
+		}
+	}
+}
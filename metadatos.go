@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datos que declara la fuente sobre un proxy, sin verificar
+type MetadatosFuente struct {
+	Pais               string `json:"country,omitempty"`
+	Anonimato          string `json:"anonymity,omitempty"`
+	UltimaVerificacion string `json:"last_checked,omitempty"`
+}
+
+// Lo que comprobo el verificador, para comparar con lo declarado por la fuente
+type DatosVerificados struct {
+	Funcional          bool      `json:"working"`
+	Pais               string    `json:"country,omitempty"`
+	UltimaVerificacion time.Time `json:"last_checked"`
+	DatosResultado
+	Inteligencia []InfoHost `json:"intel,omitempty"`
+}
+
+type EntradaJSON struct {
+	IDEjecucion string            `json:"run_id"`
+	Proxy       string            `json:"proxy"`
+	Tipo        string            `json:"type"`
+	IPv6        bool              `json:"ipv6,omitempty"`
+	PrimeraVez  *time.Time        `json:"first_seen,omitempty"`
+	Listas      []string          `json:"denylists,omitempty"`
+	Fuente      *MetadatosFuente  `json:"source,omitempty"`
+	Verificado  *DatosVerificados `json:"checked,omitempty"`
+}
+
+var (
+	patronFecha     = regexp.MustCompile(`^\d{4}[-/]\d{2}[-/]\d{2}`)
+	patronHora      = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?$`)
+	patronTimestamp = regexp.MustCompile(`^\d{10}$`)
+	patronPais      = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+var nivelesAnonimato = map[string]string{
+	"elite":        "elite",
+	"hia":          "elite",
+	"high":         "elite",
+	"anonymous":    "anonymous",
+	"anonimo":      "anonymous",
+	"anm":          "anonymous",
+	"transparent":  "transparent",
+	"transparente": "transparent",
+	"noa":          "transparent",
+}
+
+// Separa una linea de una fuente en ip:puerto y columnas extra
+func separarLineaProxy(linea string, reglas ReglasCanonicas) (string, []string) {
+	campos := strings.FieldsFunc(strings.TrimSpace(linea), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ',' || r == ';' || r == '|'
+	})
+	if len(campos) == 0 {
+		return "", nil
+	}
+
+	if proxy := reglas.Canonizar(campos[0]); proxy != "" {
+		return proxy, campos[1:]
+	}
+
+	// Formato "ip,puerto,..."; JoinHostPort pone corchetes a las IPv6
+	if len(campos) >= 2 {
+		if _, err := strconv.Atoi(campos[1]); err == nil {
+			return reglas.Canonizar(net.JoinHostPort(campos[0], campos[1])), campos[2:]
+		}
+	}
+	return "", nil
+}
+
+// Interpreta columnas extra como pais, anonimato y fecha de ultima verificacion
+func ParsearMetadatosFuente(extras []string) (MetadatosFuente, bool) {
+	var metadatos MetadatosFuente
+	var fecha []string
+	for _, extra := range extras {
+		switch {
+		case patronPais.MatchString(extra):
+			metadatos.Pais = extra
+		case nivelesAnonimato[strings.ToLower(extra)] != "":
+			metadatos.Anonimato = nivelesAnonimato[strings.ToLower(extra)]
+		case patronFecha.MatchString(extra), patronHora.MatchString(extra), patronTimestamp.MatchString(extra):
+			fecha = append(fecha, extra)
+		}
+	}
+	metadatos.UltimaVerificacion = strings.Join(fecha, " ")
+	return metadatos, metadatos != MetadatosFuente{}
+}
+
+// Extrae los metadatos declarados por las fuentes para cada ip:puerto
+func ExtraerMetadatosFuente(lineas []string, reglas ReglasCanonicas) map[string]MetadatosFuente {
+	metadatos := make(map[string]MetadatosFuente)
+	for _, linea := range lineas {
+		ipPuerto, extras := separarLineaProxy(linea, reglas)
+		if ipPuerto == "" || len(extras) == 0 {
+			continue
+		}
+		if datos, ok := ParsearMetadatosFuente(extras); ok {
+			metadatos[ipPuerto] = datos
+		}
+	}
+	return metadatos
+}
+
+// Guarda proxies/<TIPO>.json con lo declarado por la fuente y lo verificado
+func (vp *VerificadorProxies) GuardarJSON(tipoProxy string, proxies []string, metadatos map[string]MetadatosFuente, resultados []Resultado) {
+	porProxy := make(map[string]Resultado)
+	for _, resultado := range resultados {
+		porProxy[resultado.Proxy] = resultado
+	}
+
+	entradas := make([]EntradaJSON, 0, len(proxies))
+	for _, proxy := range proxies {
+		entrada := EntradaJSON{IDEjecucion: vp.IDEjecucion, Proxy: proxy, Tipo: tipoProxy, IPv6: EsIPv6(proxy)}
+		if vp.Vistos != nil {
+			if primeraVez, ok := vp.Vistos.PrimeraVez(tipoProxy, proxy); ok {
+				entrada.PrimeraVez = &primeraVez
+			}
+		}
+		if vp.ListasNegras != nil {
+			entrada.Listas = vp.ListasNegras.Buscar(proxy)
+		}
+		if datos, ok := metadatos[proxy]; ok {
+			entrada.Fuente = &datos
+		}
+		if resultado, ok := porProxy[proxy]; ok {
+			entrada.Verificado = &DatosVerificados{
+				Funcional:          resultado.Funcional,
+				UltimaVerificacion: resultado.Fecha,
+				DatosResultado:     datosDeResultado(resultado),
+				Inteligencia:       resultado.Inteligencia,
+			}
+			entrada.Verificado.Pais = resultado.Pais
+		}
+		entradas = append(entradas, entrada)
+	}
+
+	dirFinal := "proxies"
+	os.MkdirAll(dirFinal, os.ModePerm)
+	rutaFinal := fmt.Sprintf("%s/%s.json", dirFinal, strings.ToUpper(tipoProxy))
+
+	datos, err := json.MarshalIndent(entradas, "", "  ")
+	if err != nil {
+		vp.Log("ERROR", fmt.Sprintf("No se pudo generar JSON de proxies %s: %v", tipoProxy, err))
+		return
+	}
+	if err := os.WriteFile(rutaFinal, datos, 0644); err != nil {
+		vp.Log("ERROR", fmt.Sprintf("No se pudo guardar %s: %v", rutaFinal, err))
+		return
+	}
+	vp.Log("INFO", fmt.Sprintf("%d proxies %s guardados en %s", len(entradas), tipoProxy, rutaFinal))
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Paso que verifica un proxy. Igual que http.RoundTripper, se puede envolver con
+// Middleware para agregar comportamiento sin tocar VerificarSOCKS4/5/HTTP
+type Verificador interface {
+	Verificar(ctx context.Context, tipoProxy, proxy string) Resultado
+}
+
+// Adapta una funcion a Verificador, como http.HandlerFunc
+type FuncionVerificador func(ctx context.Context, tipoProxy, proxy string) Resultado
+
+func (f FuncionVerificador) Verificar(ctx context.Context, tipoProxy, proxy string) Resultado {
+	return f(ctx, tipoProxy, proxy)
+}
+
+// Envuelve un Verificador
+type Middleware func(Verificador) Verificador
+
+// Aplica los middlewares sobre base. El primero es el mas externo: con
+// Encadenar(base, tasa, reintentos) cada reintento tambien respeta la tasa
+func Encadenar(base Verificador, middlewares ...Middleware) Verificador {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		base = middlewares[i](base)
+	}
+	return base
+}
+
+// Limita las verificaciones que empiezan por segundo entre todos los workers.
+// Deja de esperar cuando se cancela el ctx de la verificacion
+func LimitarTasa(porSegundo float64) Middleware {
+	intervalo := time.Duration(float64(time.Second) / porSegundo)
+	var mu sync.Mutex
+	var siguiente time.Time
+
+	return func(siguienteVerificador Verificador) Verificador {
+		return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+			mu.Lock()
+			ahora := time.Now()
+			if siguiente.Before(ahora) {
+				siguiente = ahora
+			}
+			turno := siguiente
+			siguiente = siguiente.Add(intervalo)
+			mu.Unlock()
+
+			select {
+			case <-time.After(time.Until(turno)):
+			case <-ctx.Done():
+			}
+			return siguienteVerificador.Verificar(ctx, tipoProxy, proxy)
+		})
+	}
+}
+
+// Deja al menos minimo entre el inicio de dos verificaciones a la misma IP
+// (reintentos, otro tipo u otro puerto del mismo host), para no disparar los
+// limites de tasa del proxy y que la segunda ronda falle por eso. Stream lo usa
+// para ordenar los proxies antes de repartirlos, asi la espera no ocupa un
+// worker; Middleware espacia lo que llega por otro camino, como los reintentos
+type EspaciadorHosts struct {
+	minimo     time.Duration
+	mu         sync.Mutex
+	siguientes map[string]time.Time // primer inicio permitido de cada host
+	limpiarEn  int
+}
+
+func NuevoEspaciadorHosts(minimo time.Duration) *EspaciadorHosts {
+	return &EspaciadorHosts{minimo: minimo, siguientes: make(map[string]time.Time), limpiarEn: 1024}
+}
+
+func hostProxy(proxy string) string {
+	if p, err := ParsearProxy(proxy); err == nil {
+		return p.Host
+	}
+	return proxy
+}
+
+// Reserva el proximo turno del host de proxy y lo devuelve. Si libre es true
+// solo reserva cuando el turno ya llego; si no, devuelve cuando llega sin reservar
+func (e *EspaciadorHosts) reservar(proxy string, libre bool) (time.Time, bool) {
+	host := hostProxy(proxy)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ahora := time.Now()
+	turno := e.siguientes[host]
+	if turno.Before(ahora) {
+		turno = ahora
+	} else if libre {
+		return turno, false
+	}
+	e.siguientes[host] = turno.Add(e.minimo)
+
+	// Los turnos ya pasados no limitan nada: se borran cada vez que el mapa
+	// duplica su tamano para que no crezca con cada host verificado
+	if len(e.siguientes) >= e.limpiarEn {
+		for h, siguiente := range e.siguientes {
+			if siguiente.Before(ahora) {
+				delete(e.siguientes, h)
+			}
+		}
+		e.limpiarEn = 2*len(e.siguientes) + 1024
+	}
+	return turno, true
+}
+
+// Marca en el ctx de una verificacion que Stream ya le reservo el turno
+type claveTurnoReservado struct{}
+
+type turnoReservado struct{ usado int32 }
+
+func conTurnoReservado(ctx context.Context) context.Context {
+	return context.WithValue(ctx, claveTurnoReservado{}, &turnoReservado{})
+}
+
+// Espera el turno del host antes de cada verificacion, salvo la primera de un
+// proxy que Stream ya despacho en su turno. Si se cancela el ctx mientras
+// espera, devuelve el proxy como no verificado sin contactarlo
+func (e *EspaciadorHosts) Middleware(siguiente Verificador) Verificador {
+	return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+		if t, ok := ctx.Value(claveTurnoReservado{}).(*turnoReservado); ok && atomic.CompareAndSwapInt32(&t.usado, 0, 1) {
+			return siguiente.Verificar(ctx, tipoProxy, proxy)
+		}
+		turno, _ := e.reservar(proxy, false)
+		select {
+		case <-time.After(time.Until(turno)):
+		case <-ctx.Done():
+			return Resultado{Proxy: proxy, Tipo: tipoProxy, ClaseError: ErrorCancelado, Fecha: time.Now()}
+		}
+		return siguiente.Verificar(ctx, tipoProxy, proxy)
+	})
+}
+
+// Entrega los proxies en salida a medida que llega el turno de su host. Los que
+// tienen que esperar quedan en una cola ordenada por turno mientras se siguen
+// entregando los de otros hosts
+func (e *EspaciadorHosts) Despachar(ctx context.Context, proxies []string, salida chan<- string) {
+	var demorados colaTurnos
+	siguiente := 0
+	for siguiente < len(proxies) || demorados.Len() > 0 {
+		var proxy string
+		switch {
+		case demorados.Len() > 0 && !demorados[0].turno.After(time.Now()):
+			proxy = heap.Pop(&demorados).(turnoProxy).proxy
+		case siguiente < len(proxies):
+			proxy = proxies[siguiente]
+			siguiente++
+		default:
+			select {
+			case <-time.After(time.Until(demorados[0].turno)):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		if turno, ok := e.reservar(proxy, true); !ok {
+			heap.Push(&demorados, turnoProxy{turno: turno, proxy: proxy})
+			continue
+		}
+		select {
+		case salida <- proxy:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type turnoProxy struct {
+	turno time.Time
+	proxy string
+}
+
+// Proxies demorados, el de turno mas cercano primero
+type colaTurnos []turnoProxy
+
+func (c colaTurnos) Len() int            { return len(c) }
+func (c colaTurnos) Less(i, j int) bool  { return c[i].turno.Before(c[j].turno) }
+func (c colaTurnos) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *colaTurnos) Push(x interface{}) { *c = append(*c, x.(turnoProxy)) }
+func (c *colaTurnos) Pop() interface{} {
+	anterior := *c
+	ultimo := anterior[len(anterior)-1]
+	*c = anterior[:len(anterior)-1]
+	return ultimo
+}
+
+// Indica si el proxy no contesto nada: sin respuesta no se sabe si esta caido o
+// si fue un error de red pasajero. Un rechazo o un pedido de credenciales es definitivo
+func sinRespuesta(resultado Resultado) bool {
+	return !resultado.Funcional && resultado.Estado == "" && resultado.Autenticacion == "" && resultado.Servicio == ""
+}
+
+// Repite hasta intentos veces las verificaciones en las que el proxy no contesto
+func Reintentar(intentos int, espera time.Duration) Middleware {
+	return func(siguiente Verificador) Verificador {
+		return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+			resultado := siguiente.Verificar(ctx, tipoProxy, proxy)
+			for intento := 0; intento < intentos && sinRespuesta(resultado); intento++ {
+				select {
+				case <-time.After(espera):
+				case <-ctx.Done():
+					return resultado
+				}
+				resultado = siguiente.Verificar(ctx, tipoProxy, proxy)
+			}
+			return resultado
+		})
+	}
+}
+
+// Contadores de las verificaciones que pasan por el middleware de Metricas
+type MetricasVerificacion struct {
+	Verificaciones int64
+	Funcionales    int64
+	SinRespuesta   int64
+	latenciaTotal  int64 // nanosegundos
+}
+
+func (mv *MetricasVerificacion) Middleware(siguiente Verificador) Verificador {
+	return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+		resultado := siguiente.Verificar(ctx, tipoProxy, proxy)
+		atomic.AddInt64(&mv.Verificaciones, 1)
+		atomic.AddInt64(&mv.latenciaTotal, int64(resultado.Latencia))
+		if resultado.Funcional {
+			atomic.AddInt64(&mv.Funcionales, 1)
+		} else if sinRespuesta(resultado) {
+			atomic.AddInt64(&mv.SinRespuesta, 1)
+		}
+		return resultado
+	})
+}
+
+func (mv *MetricasVerificacion) String() string {
+	verificaciones := atomic.LoadInt64(&mv.Verificaciones)
+	if verificaciones == 0 {
+		return "0 verificaciones"
+	}
+	media := time.Duration(atomic.LoadInt64(&mv.latenciaTotal) / verificaciones)
+	return fmt.Sprintf("%d verificaciones, %d funcionales, %d sin respuesta, latencia media %s",
+		verificaciones, atomic.LoadInt64(&mv.Funcionales), atomic.LoadInt64(&mv.SinRespuesta), media.Round(time.Millisecond))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Middleware que anota su nombre al entrar y al salir
+func middlewareAnotado(nombre string, orden *[]string) Middleware {
+	return func(siguiente Verificador) Verificador {
+		return FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+			*orden = append(*orden, nombre+">")
+			resultado := siguiente.Verificar(ctx, tipoProxy, proxy)
+			*orden = append(*orden, "<"+nombre)
+			return resultado
+		})
+	}
+}
+
+func TestEncadenar(t *testing.T) {
+	casos := []struct {
+		nombres  []string
+		esperado []string
+	}{
+		{nil, []string{"base"}},
+		{[]string{"a"}, []string{"a>", "base", "<a"}},
+		{[]string{"cache", "tasa", "reintentos"}, []string{"cache>", "tasa>", "reintentos>", "base", "<reintentos", "<tasa", "<cache"}},
+	}
+	for _, caso := range casos {
+		var orden []string
+		var middlewares []Middleware
+		for _, nombre := range caso.nombres {
+			middlewares = append(middlewares, middlewareAnotado(nombre, &orden))
+		}
+		base := FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+			orden = append(orden, "base")
+			return Resultado{Proxy: proxy, Tipo: tipoProxy}
+		})
+		Encadenar(base, middlewares...).Verificar(context.Background(), "http", "1.2.3.4:8080")
+		if !reflect.DeepEqual(orden, caso.esperado) {
+			t.Errorf("Encadenar(%q) = %q, se esperaba %q", caso.nombres, orden, caso.esperado)
+		}
+	}
+}
+
+func TestReintentar(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		intentos   int
+		respuestas []Resultado // lo que devuelve cada verificacion; la ultima se repite
+		llamadas   int
+		funcional  bool
+	}{
+		{nombre: "funciona al primer intento", intentos: 2, respuestas: []Resultado{{Funcional: true}}, llamadas: 1, funcional: true},
+		{nombre: "contesta al segundo", intentos: 2, respuestas: []Resultado{{ClaseError: ErrorTimeout}, {Funcional: true}}, llamadas: 2, funcional: true},
+		{nombre: "nunca contesta", intentos: 2, respuestas: []Resultado{{ClaseError: ErrorTimeout}}, llamadas: 3},
+		{nombre: "sin reintentos", intentos: 0, respuestas: []Resultado{{ClaseError: ErrorTimeout}}, llamadas: 1},
+		{nombre: "pide credenciales", intentos: 2, respuestas: []Resultado{{Autenticacion: "required"}}, llamadas: 1},
+		{nombre: "rechaza el objetivo", intentos: 2, respuestas: []Resultado{{Estado: "403"}}, llamadas: 1},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			llamadas := 0
+			base := FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+				respuesta := caso.respuestas[len(caso.respuestas)-1]
+				if llamadas < len(caso.respuestas) {
+					respuesta = caso.respuestas[llamadas]
+				}
+				llamadas++
+				return respuesta
+			})
+			resultado := Reintentar(caso.intentos, time.Millisecond)(base).Verificar(context.Background(), "socks5", "1.2.3.4:1080")
+			if llamadas != caso.llamadas || resultado.Funcional != caso.funcional {
+				t.Errorf("%d llamadas y funcional=%t, se esperaba %d y %t", llamadas, resultado.Funcional, caso.llamadas, caso.funcional)
+			}
+		})
+	}
+}
+
+func TestEspaciadorHostsMiddleware(t *testing.T) {
+	espaciador := NuevoEspaciadorHosts(time.Hour)
+	llamadas := 0
+	verificador := espaciador.Middleware(FuncionVerificador(func(ctx context.Context, tipoProxy, proxy string) Resultado {
+		llamadas++
+		return Resultado{Proxy: proxy, Tipo: tipoProxy, Funcional: true}
+	}))
+
+	// La primera verificacion de un host no espera; otra IP tampoco
+	if resultado := verificador.Verificar(context.Background(), "http", "1.2.3.4:8080"); !resultado.Funcional {
+		t.Fatalf("primera verificacion = %+v", resultado)
+	}
+	if resultado := verificador.Verificar(context.Background(), "http", "5.6.7.8:8080"); !resultado.Funcional {
+		t.Fatalf("verificacion de otro host = %+v", resultado)
+	}
+
+	// Otro puerto del mismo host tiene que esperar una hora: al cancelar
+	// vuelve como cancelado sin llegar a verificarse
+	ctx, cancelar := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelar()
+	resultado := verificador.Verificar(ctx, "socks5", "1.2.3.4:1080")
+	if resultado.Funcional || resultado.ClaseError != ErrorCancelado || llamadas != 2 {
+		t.Errorf("verificacion cancelada = %+v con %d llamadas, se esperaba %q sin llamar", resultado, llamadas, ErrorCancelado)
+	}
+
+	// Un proxy que Despachar ya entrego en su turno no vuelve a esperar, pero
+	// un reintento con el mismo ctx si
+	ctx = conTurnoReservado(context.Background())
+	if resultado := verificador.Verificar(ctx, "http", "1.2.3.4:3128"); !resultado.Funcional {
+		t.Errorf("verificacion con turno reservado = %+v", resultado)
+	}
+	ctx, cancelar = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelar()
+	if resultado := verificador.Verificar(ctx, "http", "1.2.3.4:3128"); resultado.ClaseError != ErrorCancelado {
+		t.Errorf("reintento con turno ya usado = %+v, se esperaba %q", resultado, ErrorCancelado)
+	}
+}
+
+func TestEspaciadorHostsDespachar(t *testing.T) {
+	espaciador := NuevoEspaciadorHosts(100 * time.Millisecond)
+	proxies := []string{"1.2.3.4:80", "1.2.3.4:8080", "5.6.7.8:80", "usuario:clave@1.2.3.4:3128", "9.9.9.9:80"}
+	salida := make(chan string, len(proxies))
+	inicio := time.Now()
+	espaciador.Despachar(context.Background(), proxies, salida)
+	close(salida)
+
+	var orden []string
+	for proxy := range salida {
+		orden = append(orden, proxy)
+	}
+	// Los demorados salen despues de los otros hosts, en el orden de sus turnos
+	esperado := []string{"1.2.3.4:80", "5.6.7.8:80", "9.9.9.9:80", "1.2.3.4:8080", "usuario:clave@1.2.3.4:3128"}
+	if !reflect.DeepEqual(orden, esperado) {
+		t.Errorf("Despachar entrego %q, se esperaba %q", orden, esperado)
+	}
+	if transcurrido := time.Since(inicio); transcurrido < 200*time.Millisecond {
+		t.Errorf("tres proxies del mismo host se despacharon en %s, se esperaban al menos 200ms", transcurrido)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Proxy ya separado en sus partes. Su forma de texto es la canonica que usan las
+// listas: [esquema://][usuario:clave@]host:puerto, con corchetes en las IPv6
+type Proxy struct {
+	Esquema string
+	Usuario string
+	Clave   string
+	Host    string
+	Puerto  int
+}
+
+// Interpreta una entrada ya canonizada; para texto scrapeado usar antes Canonizar
+func ParsearProxy(texto string) (Proxy, error) {
+	var proxy Proxy
+	resto := texto
+	if i := strings.Index(resto, "://"); i >= 0 {
+		proxy.Esquema = strings.ToLower(resto[:i])
+		resto = resto[i+len("://"):]
+	}
+	if i := strings.LastIndex(resto, "@"); i >= 0 {
+		proxy.Usuario, proxy.Clave, _ = strings.Cut(resto[:i], ":")
+		resto = resto[i+1:]
+	}
+	host, puerto, err := net.SplitHostPort(resto)
+	if err != nil {
+		return Proxy{}, fmt.Errorf("proxy %q: %v", texto, err)
+	}
+	proxy.Host = host
+	if proxy.Puerto, err = strconv.Atoi(puerto); err != nil || proxy.Puerto < 1 || proxy.Puerto > 65535 {
+		return Proxy{}, fmt.Errorf("proxy %q: puerto invalido", texto)
+	}
+	return proxy, nil
+}
+
+// Direccion host:puerto a la que hay que conectar
+func (p Proxy) Direccion() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Puerto))
+}
+
+func (p Proxy) ConCredenciales() bool {
+	return p.Usuario != "" || p.Clave != ""
+}
+
+func (p Proxy) EsIPv6() bool {
+	ip := net.ParseIP(p.Host)
+	return ip != nil && ip.To4() == nil
+}
+
+func (p Proxy) String() string {
+	texto := p.Direccion()
+	if p.ConCredenciales() {
+		texto = p.Usuario + ":" + p.Clave + "@" + texto
+	}
+	if p.Esquema != "" {
+		texto = p.Esquema + "://" + texto
+	}
+	return texto
+}
+
+// Un Proxy se serializa como su forma canonica en JSON, CSV y claves de mapas
+func (p Proxy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Proxy) UnmarshalText(texto []byte) error {
+	proxy, err := ParsearProxy(string(texto))
+	if err != nil {
+		return err
+	}
+	*p = proxy
+	return nil
+}
+
+// Lo que deja la verificacion de un proxy ademas de funcional, latencia y fecha.
+// -json, la salida NDJSON (y con ella -history y su CSV) y -target-cache lo
+// incrustan, asi un campo nuevo de Resultado se agrega en un solo lugar
+type DatosResultado struct {
+	Estado          string            `json:"status,omitempty"`
+	Cabeceras       map[string]string `json:"headers,omitempty"`
+	Autenticacion   string            `json:"auth,omitempty"`
+	Metodos         []string          `json:"auth_methods,omitempty"`
+	Servicio        string            `json:"service,omitempty"`
+	Banner          string            `json:"banner,omitempty"`
+	Bind            bool              `json:"bind,omitempty"`
+	TLS             string            `json:"tls,omitempty"`
+	AsercionFallida string            `json:"failed_assertion,omitempty"`
+	FalloConsumo    string            `json:"consumer_failure,omitempty"`
+	ClaseError      string            `json:"error,omitempty"`
+	RDNS            string            `json:"rdns,omitempty"`
+	Faltantes       []string          `json:"missing,omitempty"`
+}
+
+func datosDeResultado(resultado Resultado) DatosResultado {
+	return DatosResultado{
+		Estado:          resultado.Estado,
+		Cabeceras:       resultado.Cabeceras,
+		Autenticacion:   resultado.Autenticacion,
+		Metodos:         resultado.Metodos,
+		Servicio:        resultado.Servicio,
+		Banner:          resultado.Banner,
+		Bind:            resultado.Bind,
+		TLS:             resultado.TLS,
+		AsercionFallida: resultado.AsercionFallida,
+		FalloConsumo:    resultado.FalloConsumo,
+		ClaseError:      resultado.ClaseError,
+		RDNS:            resultado.RDNS,
+		Faltantes:       resultado.Faltantes,
+	}
+}
+
+// Copia los datos sobre un Resultado, por ejemplo al reutilizar uno guardado
+func (d DatosResultado) aplicar(resultado *Resultado) {
+	resultado.Estado = d.Estado
+	resultado.Cabeceras = d.Cabeceras
+	resultado.Autenticacion = d.Autenticacion
+	resultado.Metodos = d.Metodos
+	resultado.Servicio = d.Servicio
+	resultado.Banner = d.Banner
+	resultado.Bind = d.Bind
+	resultado.TLS = d.TLS
+	resultado.AsercionFallida = d.AsercionFallida
+	resultado.FalloConsumo = d.FalloConsumo
+	resultado.ClaseError = d.ClaseError
+	resultado.RDNS = d.RDNS
+	resultado.Faltantes = d.Faltantes
+}
+
+// Clases de error de Resultado.ClaseError
+const (
+	ErrorRechazado = "refused"   // conexion rechazada (RST al conectar)
+	ErrorTimeout   = "timeout"   // sin respuesta dentro de -timeout
+	ErrorReset     = "reset"     // la conexion se corto con RST despues de abrirse
+	ErrorCerrado   = "closed"    // el proxy cerro la conexion sin responder completo
+	ErrorDNS       = "dns"       // no se pudo resolver el host
+	ErrorCancelado = "canceled"  // la ejecucion se cancelo
+	ErrorRed       = "network"   // otro error de red
+	ErrorProtocolo = "protocol"  // respondio otro servicio u otro protocolo
+	ErrorAuth      = "auth"      // exige credenciales o las rechazo
+	ErrorDenegado  = "denied"    // contesto el protocolo pero nego la conexion al objetivo
+	ErrorTLS       = "tls"       // fallo la verificacion de -tls-target
+	ErrorAsercion  = "assertion" // no cumplio -assert
+	ErrorConsumo   = "consumer"  // no aguanto la simulacion de -consumer-url
+)
+
+// Clase de un error de red o de lectura durante la verificacion
+func ClasificarError(err error) string {
+	var errorDNS *net.DNSError
+	var errorRed net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCancelado
+	case errors.As(err, &errorDNS):
+		return ErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRechazado
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorReset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorCerrado
+	case errors.As(err, &errorRed) && errorRed.Timeout():
+		return ErrorTimeout
+	default:
+		return ErrorRed
+	}
+}
+
+// Clase de un resultado no funcional en el que el proxy si respondio
+func ClaseRechazo(resultado Resultado) string {
+	switch {
+	case resultado.Servicio != "" || resultado.Banner != "":
+		return ErrorProtocolo
+	case resultado.Autenticacion == "required":
+		return ErrorAuth
+	case resultado.TLS != "" && resultado.TLS != "ok":
+		return ErrorTLS
+	case resultado.AsercionFallida != "":
+		return ErrorAsercion
+	case resultado.FalloConsumo != "":
+		return ErrorConsumo
+	default:
+		return ErrorDenegado
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sumidero que publica en un broker MQTT 3.1.1 los proxies funcionales
+// (<topic>/proxies/<tipo>) y estadisticas por tipo (<topic>/stats/<tipo>, retenidas)
+type SumideroMQTT struct {
+	Broker   *url.URL
+	Topico   string
+	conexion net.Conn
+	mu       sync.Mutex
+}
+
+// Sumideros que ademas aceptan estadisticas al terminar cada tipo
+type SumideroEstadisticas interface {
+	PublicarEstadisticas(ctx context.Context, idEjecucion, tipoProxy string, verificados, funcionales int) error
+}
+
+// Esquemas aceptados en -mqtt y su puerto por defecto; mqtts y ssl van sobre TLS
+var puertosMQTT = map[string]string{"mqtt": "1883", "tcp": "1883", "mqtts": "8883", "ssl": "8883"}
+
+func NuevoSumideroMQTT(broker, topico string) (*SumideroMQTT, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	// Sin esquema "host:1883" se parsea como esquema "host" y sin host
+	puerto, ok := puertosMQTT[u.Scheme]
+	if !ok || u.Hostname() == "" {
+		return nil, fmt.Errorf("se esperaba mqtt://, tcp://, mqtts:// o ssl:// seguido de host[:puerto]")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), puerto)
+	}
+	sm := &SumideroMQTT{Broker: u, Topico: topico}
+	if err := sm.conectar(context.Background()); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+func (sm *SumideroMQTT) Publicar(ctx context.Context, registro RegistroNDJSON) error {
+	datos, err := json.Marshal(registro)
+	if err != nil {
+		return err
+	}
+	return sm.publicar(ctx, fmt.Sprintf("%s/proxies/%s", sm.Topico, registro.Tipo), datos, false)
+}
+
+func (sm *SumideroMQTT) PublicarEstadisticas(ctx context.Context, idEjecucion, tipoProxy string, verificados, funcionales int) error {
+	datos, err := json.Marshal(map[string]interface{}{
+		"run_id":    idEjecucion,
+		"type":      tipoProxy,
+		"checked":   verificados,
+		"working":   funcionales,
+		"timestamp": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return sm.publicar(ctx, fmt.Sprintf("%s/stats/%s", sm.Topico, tipoProxy), datos, true)
+}
+
+func (sm *SumideroMQTT) Cerrar() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.conexion == nil {
+		return nil
+	}
+	sm.conexion.Write([]byte{0xE0, 0x00})
+	err := sm.conexion.Close()
+	sm.conexion = nil
+	return err
+}
+
+// Publica con QoS 0, reconectando una vez si la conexion se cayo
+func (sm *SumideroMQTT) publicar(ctx context.Context, topico string, datos []byte, retener bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var cuerpo []byte
+	cuerpo = append(cuerpo, cadenaMQTT(topico)...)
+	cuerpo = append(cuerpo, datos...)
+	cabecera := byte(0x30)
+	if retener {
+		cabecera |= 0x01
+	}
+	paquete := paqueteMQTT(cabecera, cuerpo)
+
+	if sm.conexion != nil {
+		sm.conexion.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := sm.conexion.Write(paquete); err == nil {
+			return nil
+		}
+		sm.conexion.Close()
+		sm.conexion = nil
+	}
+	if err := sm.conectar(ctx); err != nil {
+		return err
+	}
+	sm.conexion.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := sm.conexion.Write(paquete)
+	return err
+}
+
+func (sm *SumideroMQTT) conectar(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conexion net.Conn
+	var err error
+	if sm.Broker.Scheme == "mqtts" || sm.Broker.Scheme == "ssl" {
+		dialerTLS := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: sm.Broker.Hostname()}}
+		conexion, err = dialerTLS.DialContext(ctx, "tcp", sm.Broker.Host)
+	} else {
+		conexion, err = dialer.DialContext(ctx, "tcp", sm.Broker.Host)
+	}
+	if err != nil {
+		return err
+	}
+	conexion.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// Sesion limpia y sin keepalive: el broker no corta la conexion aunque pasen
+	// minutos sin proxies nuevos
+	banderas := byte(0x02)
+	var cargaUtil []byte
+	host, _ := os.Hostname()
+	cargaUtil = append(cargaUtil, cadenaMQTT(fmt.Sprintf("ultraproxy-%s-%d", host, os.Getpid()))...)
+	if usuario := sm.Broker.User; usuario != nil {
+		banderas |= 0x80
+		cargaUtil = append(cargaUtil, cadenaMQTT(usuario.Username())...)
+		if clave, ok := usuario.Password(); ok {
+			banderas |= 0x40
+			cargaUtil = append(cargaUtil, cadenaMQTT(clave)...)
+		}
+	}
+	cuerpo := append(cadenaMQTT("MQTT"), 0x04, banderas, 0x00, 0x00)
+	cuerpo = append(cuerpo, cargaUtil...)
+
+	if _, err := conexion.Write(paqueteMQTT(0x10, cuerpo)); err != nil {
+		conexion.Close()
+		return err
+	}
+
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conexion, connack); err != nil {
+		conexion.Close()
+		return err
+	}
+	if connack[0] != 0x20 || connack[3] != 0x00 {
+		conexion.Close()
+		return fmt.Errorf("el broker rechazo la conexion (codigo %d)", connack[3])
+	}
+
+	conexion.SetDeadline(time.Time{})
+	sm.conexion = conexion
+	return nil
+}
+
+// Cadena con prefijo de largo de 2 bytes
+func cadenaMQTT(texto string) []byte {
+	datos := make([]byte, 2, 2+len(texto))
+	binary.BigEndian.PutUint16(datos, uint16(len(texto)))
+	return append(datos, texto...)
+}
+
+// Paquete con cabecera fija y largo restante codificado en base 128
+func paqueteMQTT(cabecera byte, cuerpo []byte) []byte {
+	paquete := []byte{cabecera}
+	largo := len(cuerpo)
+	for {
+		digito := byte(largo % 128)
+		largo /= 128
+		if largo > 0 {
+			digito |= 0x80
+		}
+		paquete = append(paquete, digito)
+		if largo == 0 {
+			break
+		}
+	}
+	return append(paquete, cuerpo...)
+}
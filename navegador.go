@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Tiempo maximo que se deja correr al navegador por fuente
+const tiempoMaximoNavegador = 60 * time.Second
+
+// Textos de las paginas intermedias "verifica que eres humano" mas comunes
+var marcasDesafio = []string{
+	"just a moment...",
+	"cf-chl",
+	"challenge-platform",
+	"verify you are human",
+	"checking your browser",
+	"ddos-guard",
+	"enable javascript and cookies",
+}
+
+// Indica si lo descargado parece una pagina de desafio en lugar de la lista
+func EsDesafioAntiBot(lineas []string) bool {
+	limite := len(lineas)
+	if limite > 200 {
+		limite = 200
+	}
+	texto := strings.ToLower(strings.Join(lineas[:limite], "\n"))
+	for _, marca := range marcasDesafio {
+		if strings.Contains(texto, marca) {
+			return true
+		}
+	}
+	return false
+}
+
+// Carga la URL con Chrome/Chromium headless (-chrome) para que se ejecute el
+// JavaScript del desafio y devuelve el texto del DOM resultante junto a los
+// proxies de sus tablas
+func (vp *VerificadorProxies) LeerConNavegador(ctx context.Context, direccion string) ([]string, error) {
+	ctx, cancelar := context.WithTimeout(ctx, tiempoMaximoNavegador)
+	defer cancelar()
+
+	argumentos := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--virtual-time-budget=15000",
+		"--dump-dom",
+	}
+	if agente := vp.CabecerasConnect.Get("User-Agent"); agente != "" {
+		argumentos = append(argumentos, "--user-agent="+agente)
+	}
+	argumentos = append(argumentos, direccion)
+
+	salida, err := exec.CommandContext(ctx, vp.Navegador, argumentos...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("navegador headless: %v", err)
+	}
+	dom := string(salida)
+
+	lineas := strings.Split(html.UnescapeString(etiquetaHTML.ReplaceAllString(dom, "")), "\n")
+	if EsDesafioAntiBot(lineas) {
+		return nil, fmt.Errorf("el desafio anti-bot sigue presente despues de cargar con el navegador")
+	}
+	return append(lineas, ExtraerProxiesDeTexto(dom)...), nil
+}
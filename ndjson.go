@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Datos adicionales de un proxy en la salida NDJSON
+type MetadatosNDJSON struct {
+	Fuente     *MetadatosFuente `json:"source,omitempty"`
+	PrimeraVez *time.Time       `json:"first_seen,omitempty"`
+	Pais       string           `json:"country,omitempty"`
+	IPv6       bool             `json:"ipv6,omitempty"`
+	Listas     []string         `json:"denylists,omitempty"`
+	DatosResultado
+}
+
+// Una linea de la salida NDJSON
+type RegistroNDJSON struct {
+	IDEjecucion string          `json:"run_id"`
+	Tipo        string          `json:"type"`
+	Direccion   string          `json:"address"`
+	Latencia    int64           `json:"latency_ms"`
+	Metadatos   MetadatosNDJSON `json:"metadata"`
+	Fecha       time.Time       `json:"timestamp"`
+}
+
+// Destino al que se envia cada proxy funcional apenas se verifica. Publicar no
+// debe bloquear mas alla de la cancelacion de ctx
+type Sumidero interface {
+	Publicar(ctx context.Context, registro RegistroNDJSON) error
+	Cerrar() error
+}
+
+// Escribe un objeto JSON por linea y vacia el buffer en cada escritura para
+// que se pueda seguir el archivo con tail -f
+type EscritorNDJSON struct {
+	archivo  *os.File
+	escritor *bufio.Writer
+	mu       sync.Mutex
+}
+
+func NuevoEscritorNDJSON(ruta string) (*EscritorNDJSON, error) {
+	archivo, err := os.Create(ruta)
+	if err != nil {
+		return nil, err
+	}
+	return &EscritorNDJSON{archivo: archivo, escritor: bufio.NewWriter(archivo)}, nil
+}
+
+func (en *EscritorNDJSON) Publicar(ctx context.Context, registro RegistroNDJSON) error {
+	datos, err := json.Marshal(registro)
+	if err != nil {
+		return err
+	}
+
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.escritor.Write(datos)
+	en.escritor.WriteByte('\n')
+	return en.escritor.Flush()
+}
+
+func (en *EscritorNDJSON) Cerrar() error {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.escritor.Flush()
+	return en.archivo.Close()
+}
+
+// Arma la linea NDJSON de un resultado con los metadatos conocidos del proxy
+func (vp *VerificadorProxies) RegistroNDJSON(resultado Resultado, metadatos map[string]MetadatosFuente) RegistroNDJSON {
+	registro := RegistroNDJSON{
+		IDEjecucion: vp.IDEjecucion,
+		Tipo:        resultado.Tipo,
+		Direccion:   resultado.Proxy,
+		Latencia:    resultado.Latencia.Milliseconds(),
+		Fecha:       resultado.Fecha,
+		Metadatos: MetadatosNDJSON{
+			IPv6:           EsIPv6(resultado.Proxy),
+			DatosResultado: datosDeResultado(resultado),
+		},
+	}
+	if datos, ok := metadatos[resultado.Proxy]; ok {
+		registro.Metadatos.Fuente = &datos
+	}
+	if vp.Vistos != nil {
+		if primeraVez, ok := vp.Vistos.PrimeraVez(resultado.Tipo, resultado.Proxy); ok {
+			registro.Metadatos.PrimeraVez = &primeraVez
+		}
+	}
+	if vp.GeoIP != nil {
+		registro.Metadatos.Pais = vp.GeoIP.Pais(resultado.Proxy)
+	}
+	if vp.ListasNegras != nil {
+		registro.Metadatos.Listas = vp.ListasNegras.Buscar(resultado.Proxy)
+	}
+	return registro
+}
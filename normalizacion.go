@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Reglas para llevar una entrada scrapeada a su forma canonica
+type ReglasCanonicas struct {
+	ConservarEsquema      bool // mantiene "socks5://" y similares si la entrada los trae
+	ConservarCredenciales bool // mantiene usuario:clave@ (tambien desde el formato ip:puerto:usuario:clave)
+	HostMinusculas        bool // pasa los nombres de host a minusculas
+	ColapsarPuertos       bool // quita ceros a la izquierda del puerto (08080 -> 8080)
+}
+
+// Reglas usadas por Nuevo: solo ip:puerto, comparable entre fuentes
+var ReglasCanonicasPorDefecto = ReglasCanonicas{HostMinusculas: true, ColapsarPuertos: true}
+
+// Forma canonica de una entrada [esquema://][usuario:clave@]host:puerto[:usuario:clave],
+// o "" si no tiene host y un puerto entre 1 y 65535. Las IPv6 van entre
+// corchetes: [2001:db8::1]:1080; sin ellos no se puede saber donde termina la IP
+func (rc ReglasCanonicas) Canonizar(entrada string) string {
+	var esquema, credenciales string
+	if i := strings.Index(entrada, "://"); i >= 0 {
+		esquema = strings.ToLower(entrada[:i])
+		entrada = entrada[i+len("://"):]
+	}
+	if i := strings.LastIndex(entrada, "@"); i >= 0 {
+		credenciales = entrada[:i]
+		entrada = entrada[i+1:]
+	}
+
+	entrada = quitarPrefijoMapeado(entrada)
+	partes := strings.Split(entrada, ":")
+	if strings.HasPrefix(entrada, "[") {
+		fin := strings.Index(entrada, "]")
+		if fin < 0 {
+			return ""
+		}
+		ip := net.ParseIP(entrada[1:fin])
+		if ip == nil {
+			return ""
+		}
+		partes = append([]string{"[" + ip.String() + "]"}, strings.Split(entrada[fin+1:], ":")[1:]...)
+	} else if len(partes) != 2 && len(partes) != 4 {
+		// Solo host:puerto o host:puerto:usuario:clave; otra cantidad de ':'
+		// es una IPv6 sin corchetes y partirla daria un host y puerto falsos
+		return ""
+	}
+	if len(partes) < 2 || partes[0] == "" || !puertoValido(partes[1]) {
+		return ""
+	}
+	host, puerto := NormalizarIPv4(partes[0]), partes[1]
+	if credenciales == "" && len(partes) >= 4 {
+		credenciales = partes[2] + ":" + partes[3]
+	}
+
+	if rc.HostMinusculas {
+		host = strings.ToLower(host)
+	}
+	if rc.ColapsarPuertos {
+		valor, _ := strconv.Atoi(puerto)
+		puerto = strconv.Itoa(valor)
+	}
+
+	canonica := host + ":" + puerto
+	if rc.ConservarCredenciales && credenciales != "" {
+		canonica = credenciales + "@" + canonica
+	}
+	if rc.ConservarEsquema && esquema != "" {
+		canonica = esquema + "://" + canonica
+	}
+	return canonica
+}
+
+// Direccion host:puerto a la que hay que conectar, sin esquema ni credenciales
+func DireccionProxy(proxy string) string {
+	if p, err := ParsearProxy(proxy); err == nil {
+		return p.Direccion()
+	}
+	return proxy
+}
+
+// Indica si el proxy es una IPv6 literal, alcanzable solo desde redes con IPv6
+func EsIPv6(proxy string) bool {
+	p, err := ParsearProxy(proxy)
+	return err == nil && p.EsIPv6()
+}
+
+// Separa los proxies IPv6 del resto, conservando el orden
+func SepararIPv6(proxies []string) ([]string, []string) {
+	var resto, ipv6 []string
+	for _, proxy := range proxies {
+		if EsIPv6(proxy) {
+			ipv6 = append(ipv6, proxy)
+		} else {
+			resto = append(resto, proxy)
+		}
+	}
+	return resto, ipv6
+}
+
+// Usuario y clave de una entrada usuario:clave@host:puerto, si los tiene
+func CredencialesProxy(proxy string) (string, string, bool) {
+	p, err := ParsearProxy(proxy)
+	return p.Usuario, p.Clave, err == nil && p.ConCredenciales()
+}
+
+// Quita el prefijo IPv4 mapeado en IPv6 ("::ffff:1.2.3.4:80" o "[::ffff:1.2.3.4]:80")
+func quitarPrefijoMapeado(direccion string) string {
+	minusculas := strings.ToLower(direccion)
+	if strings.HasPrefix(minusculas, "[::ffff:") {
+		if fin := strings.Index(direccion, "]"); fin > 0 {
+			return direccion[len("[::ffff:"):fin] + direccion[fin+1:]
+		}
+	}
+	if strings.HasPrefix(minusculas, "::ffff:") {
+		return direccion[len("::ffff:"):]
+	}
+	return direccion
+}
+
+// Lleva a la forma a.b.c.d las IPv4 con octetos rellenos de ceros (001.002.003.004)
+// o escritas como un entero decimal (16909060). Cualquier otro host se devuelve igual
+func NormalizarIPv4(host string) string {
+	if host == "" {
+		return host
+	}
+
+	if !strings.Contains(host, ".") {
+		if !soloDigitos(host) {
+			return host
+		}
+		valor, err := strconv.ParseUint(host, 10, 32)
+		if err != nil {
+			return host
+		}
+		return strconv.FormatUint(valor>>24, 10) + "." + strconv.FormatUint(valor>>16&0xFF, 10) + "." +
+			strconv.FormatUint(valor>>8&0xFF, 10) + "." + strconv.FormatUint(valor&0xFF, 10)
+	}
+
+	octetos := strings.Split(host, ".")
+	if len(octetos) != 4 {
+		return host
+	}
+	for i, octeto := range octetos {
+		// Los ceros a la izquierda se leen en decimal: en listas scrapeadas son relleno, no octal
+		if !soloDigitos(octeto) {
+			return host
+		}
+		valor, err := strconv.ParseUint(octeto, 10, 8)
+		if err != nil {
+			return host
+		}
+		octetos[i] = strconv.FormatUint(valor, 10)
+	}
+	return strings.Join(octetos, ".")
+}
+
+// Indica si el texto es un puerto TCP en decimal, admitiendo ceros a la izquierda
+func puertoValido(texto string) bool {
+	if !soloDigitos(texto) {
+		return false
+	}
+	valor, err := strconv.Atoi(texto)
+	return err == nil && valor >= 1 && valor <= 65535
+}
+
+func soloDigitos(texto string) bool {
+	if texto == "" {
+		return false
+	}
+	for _, r := range texto {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Configura un VerificadorProxies creado con Nuevo
+type Opcion func(vp *VerificadorProxies) error
+
+// Crea un verificador con los valores de la CLI (timeout 5s, 50 workers,
+// objetivo 1.1.1.1:80, sin reintentos de fuentes) y le aplica las opciones en orden
+func Nuevo(opciones ...Opcion) (*VerificadorProxies, error) {
+	ctx, cancelar := context.WithCancel(context.Background())
+	vp := &VerificadorProxies{
+		URLsProxies:        make(map[string][]string),
+		Timeout:            5 * time.Second,
+		EsperaReintento:    time.Second,
+		TrabajadoresMax:    50,
+		ContextoCancelable: ctx,
+		FuncionCancelar:    cancelar,
+		Canonicas:          ReglasCanonicasPorDefecto,
+		IDEjecucion:        NuevoIDEjecucion(time.Now()),
+	}
+	if err := ConObjetivo("1.1.1.1:80")(vp); err != nil {
+		cancelar()
+		return nil, err
+	}
+	for _, opcion := range opciones {
+		if err := opcion(vp); err != nil {
+			cancelar()
+			return nil, err
+		}
+	}
+	return vp, nil
+}
+
+// Timeout de cada conexion y handshake con un proxy
+func ConTimeout(timeout time.Duration) Opcion {
+	return func(vp *VerificadorProxies) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout invalido %s", timeout)
+		}
+		vp.Timeout = timeout
+		return nil
+	}
+}
+
+// Verificaciones concurrentes cuando Ejecutar, VerificarLista o Stream reciben maxChecks 0
+func ConTrabajadores(trabajadores int) Opcion {
+	return func(vp *VerificadorProxies) error {
+		if trabajadores < 1 {
+			return fmt.Errorf("cantidad de workers invalida %d", trabajadores)
+		}
+		vp.TrabajadoresMax = trabajadores
+		return nil
+	}
+}
+
+// IP y puerto ("ip:puerto") a los que se pide conectar a traves de cada proxy.
+// Tiene que ser una IPv4: la solicitud SOCKS4 y SOCKS5 lleva sus 4 bytes
+func ConObjetivo(objetivo string) Opcion {
+	return func(vp *VerificadorProxies) error {
+		ip, puerto, err := parsearObjetivo(objetivo)
+		if err != nil {
+			return err
+		}
+		vp.Objetivo, vp.IPObjetivo, vp.PuertoObjetivo = objetivo, ip, puerto
+		return nil
+	}
+}
+
+func parsearObjetivo(objetivo string) (string, int, error) {
+	host, textoPuerto, err := net.SplitHostPort(objetivo)
+	if err != nil {
+		return "", 0, fmt.Errorf("objetivo %q: %v", objetivo, err)
+	}
+	if net.ParseIP(host).To4() == nil {
+		return "", 0, fmt.Errorf("objetivo %q: se esperaba una IPv4", objetivo)
+	}
+	puerto, err := strconv.Atoi(textoPuerto)
+	if err != nil || puerto < 1 || puerto > 65535 {
+		return "", 0, fmt.Errorf("objetivo %q: puerto invalido", objetivo)
+	}
+	return host, puerto, nil
+}
+
+// URLs de las fuentes por tipo de proxy, como en urls.json
+func ConFuentes(urlsProxies map[string][]string) Opcion {
+	return func(vp *VerificadorProxies) error {
+		vp.URLsProxies = urlsProxies
+		return nil
+	}
+}
+
+// Reintentos de cada fuente que falla y la espera entre ellos
+func ConReintentos(reintentos int, espera time.Duration) Opcion {
+	return func(vp *VerificadorProxies) error {
+		vp.ReintentosMax, vp.EsperaReintento = reintentos, espera
+		return nil
+	}
+}
+
+// Registro persistente de proxies vistos (fechas de primera y ultima vez)
+func ConRegistroVistos(vistos *RegistroVistos) Opcion {
+	return func(vp *VerificadorProxies) error {
+		vp.Vistos = vistos
+		return nil
+	}
+}
+
+// Funciones que reciben cada linea de log y el progreso; nil deja el comportamiento por defecto
+func ConEventos(alLog func(string), alProgreso func(int)) Opcion {
+	return func(vp *VerificadorProxies) error {
+		vp.CallbackLog, vp.CallbackProgreso = alLog, alProgreso
+		return nil
+	}
+}
+
+// Reemplaza el net.Dialer con el que Conectar abre cada conexion, por ejemplo
+// para salir por otro proxy o por una red de pruebas. ControlConexion no se aplica
+func ConDialer(dialer func(ctx context.Context, red, direccion string) (net.Conn, error)) Opcion {
+	return func(vp *VerificadorProxies) error {
+		vp.Dialer = dialer
+		return nil
+	}
+}
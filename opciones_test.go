@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConObjetivo(t *testing.T) {
+	casos := []struct {
+		objetivo string
+		ip       string
+		puerto   int
+		error    bool
+	}{
+		{objetivo: "1.1.1.1:80", ip: "1.1.1.1", puerto: 80},
+		{objetivo: "10.0.0.1:65535", ip: "10.0.0.1", puerto: 65535},
+		{objetivo: "example.com:80", error: true},
+		{objetivo: "[2001:db8::1]:80", error: true},
+		{objetivo: "1.1.1.1", error: true},
+		{objetivo: "1.1.1.1:0", error: true},
+		{objetivo: "1.1.1.1:65536", error: true},
+		{objetivo: "1.1.1.1:http", error: true},
+	}
+	for _, caso := range casos {
+		vp, err := Nuevo(ConObjetivo(caso.objetivo))
+		if caso.error {
+			if err == nil {
+				t.Errorf("ConObjetivo(%q) no devolvio error", caso.objetivo)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ConObjetivo(%q): %v", caso.objetivo, err)
+			continue
+		}
+		if vp.IPObjetivo != caso.ip || vp.PuertoObjetivo != caso.puerto {
+			t.Errorf("ConObjetivo(%q) = %s %d, se esperaba %s %d", caso.objetivo, vp.IPObjetivo, vp.PuertoObjetivo, caso.ip, caso.puerto)
+		}
+		vp.FuncionCancelar()
+	}
+}
+
+func TestNuevoVerificadorProxies(t *testing.T) {
+	casos := []struct {
+		timeout      time.Duration
+		trabajadores int
+		objetivo     string
+		avisos       int
+	}{
+		{timeout: 2 * time.Second, trabajadores: 10, objetivo: "8.8.8.8:53"},
+		{timeout: 0, trabajadores: 10, objetivo: "8.8.8.8:53", avisos: 1},
+		{timeout: 2 * time.Second, trabajadores: 0, objetivo: "example.com:80", avisos: 2},
+	}
+	for _, caso := range casos {
+		var avisos int
+		vp := NuevoVerificadorProxies(nil, caso.timeout, 0, 0, caso.trabajadores, func(string) { avisos++ }, nil, caso.objetivo)
+		if avisos != caso.avisos {
+			t.Errorf("%+v: %d avisos, se esperaban %d", caso, avisos, caso.avisos)
+		}
+		// Lo invalido queda con el valor por defecto de Nuevo, nunca en cero
+		if vp.Timeout <= 0 || vp.TrabajadoresMax < 1 || vp.IPObjetivo == "" {
+			t.Errorf("%+v: quedo timeout %s, %d workers, objetivo %q", caso, vp.Timeout, vp.TrabajadoresMax, vp.Objetivo)
+		}
+		vp.FuncionCancelar()
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Pastes que se leen como maximo de cada listado (usuario o archivo de Pastebin, usuario de Gist)
+const maxPastesPorListado = 25
+
+// Claves de pastes enlazadas desde las paginas de listado de Pastebin
+var enlacePastebin = regexp.MustCompile(`href="/([A-Za-z0-9]{8})"`)
+
+// URL del texto plano de un paste individual de Pastebin o Rentry.
+// Cualquier otra URL se devuelve igual
+func URLRawPaste(direccion string) string {
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return direccion
+	}
+	ruta := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") {
+	case "pastebin.com":
+		if len(ruta) == 1 && len(ruta[0]) == 8 {
+			return "https://pastebin.com/raw/" + ruta[0]
+		}
+	case "rentry.co", "rentry.org":
+		if len(ruta) == 1 && ruta[0] != "" {
+			return fmt.Sprintf("https://%s/%s/raw", u.Hostname(), ruta[0])
+		}
+	}
+	return direccion
+}
+
+// Lee fuentes que no son un archivo de texto: listados de pastes de un usuario
+// de Pastebin, el archivo de pastes recientes y gists (uno o todos los de un usuario).
+// El segundo valor indica si la URL corresponde a alguno de estos adaptadores
+func (vp *VerificadorProxies) LeerPastes(ctx context.Context, cliente *http.Client, direccion string) ([]string, bool, error) {
+	u, err := url.Parse(direccion)
+	if err != nil {
+		return nil, false, nil
+	}
+	ruta := strings.Split(strings.Trim(u.Path, "/"), "/")
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	var raws []string
+	switch {
+	case host == "pastebin.com" && ((len(ruta) == 2 && ruta[0] == "u") || (len(ruta) == 1 && ruta[0] == "archive")):
+		pagina, err := vp.descargarTexto(ctx, cliente, direccion)
+		if err != nil {
+			return nil, true, err
+		}
+		vistas := make(map[string]bool)
+		for _, coincidencia := range enlacePastebin.FindAllStringSubmatch(string(pagina), -1) {
+			if clave := coincidencia[1]; !vistas[clave] && len(raws) < maxPastesPorListado {
+				vistas[clave] = true
+				raws = append(raws, "https://pastebin.com/raw/"+clave)
+			}
+		}
+
+	case host == "gist.github.com" && len(ruta) == 1 && ruta[0] != "":
+		var gists []gistGitHub
+		if err := vp.descargarJSON(ctx, cliente, fmt.Sprintf("https://api.github.com/users/%s/gists?per_page=%d", ruta[0], maxPastesPorListado), &gists); err != nil {
+			return nil, true, err
+		}
+		for _, gist := range gists {
+			for _, archivo := range gist.Archivos {
+				raws = append(raws, archivo.URLRaw)
+			}
+		}
+
+	case host == "gist.github.com" && len(ruta) == 2:
+		var gist gistGitHub
+		if err := vp.descargarJSON(ctx, cliente, "https://api.github.com/gists/"+ruta[1], &gist); err != nil {
+			return nil, true, err
+		}
+		var lineas []string
+		for _, archivo := range gist.Archivos {
+			if archivo.Truncado || archivo.Contenido == "" {
+				raws = append(raws, archivo.URLRaw)
+				continue
+			}
+			lineas = append(lineas, strings.Split(archivo.Contenido, "\n")...)
+		}
+		if len(raws) == 0 {
+			return lineas, true, nil
+		}
+		restantes, err := vp.leerRaws(ctx, cliente, raws)
+		return append(lineas, restantes...), true, err
+
+	default:
+		return nil, false, nil
+	}
+
+	lineas, err := vp.leerRaws(ctx, cliente, raws)
+	return lineas, true, err
+}
+
+// Gist segun la API de GitHub
+type gistGitHub struct {
+	Archivos map[string]struct {
+		URLRaw    string `json:"raw_url"`
+		Contenido string `json:"content"`
+		Truncado  bool   `json:"truncated"`
+	} `json:"files"`
+}
+
+// Junta las lineas de varios pastes; los que fallan se registran y se omiten
+func (vp *VerificadorProxies) leerRaws(ctx context.Context, cliente *http.Client, raws []string) ([]string, error) {
+	var lineas []string
+	leidos := 0
+	for _, raw := range raws {
+		if ctx.Err() != nil {
+			return lineas, ctx.Err()
+		}
+		if err := vp.FuentePermitida(raw); err != nil {
+			vp.Log("WARNING", fmt.Sprintf("Paste %s omitido: %v", raw, err))
+			continue
+		}
+		cuerpo, err := vp.descargarTexto(ctx, cliente, raw)
+		if err != nil {
+			vp.Log("WARNING", fmt.Sprintf("No se pudo leer el paste %s: %v", raw, err))
+			continue
+		}
+		lineas = append(lineas, strings.Split(string(cuerpo), "\n")...)
+		leidos++
+	}
+	if leidos == 0 && len(raws) > 0 {
+		return nil, fmt.Errorf("no se pudo leer ninguno de los %d pastes", len(raws))
+	}
+	return lineas, nil
+}
+
+func (vp *VerificadorProxies) descargarTexto(ctx context.Context, cliente *http.Client, direccion string) ([]byte, error) {
+	return vp.descargarConCabeceras(ctx, cliente, direccion, nil)
+}
+
+func (vp *VerificadorProxies) descargarConCabeceras(ctx context.Context, cliente *http.Client, direccion string, cabeceras http.Header) ([]byte, error) {
+	solicitud, err := http.NewRequestWithContext(ctx, http.MethodGet, direccion, nil)
+	if err != nil {
+		return nil, err
+	}
+	for nombre, valores := range cabeceras {
+		solicitud.Header[nombre] = valores
+	}
+	resp, err := cliente.Do(solicitud)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("estado %d", resp.StatusCode)
+	}
+	return leerDescarga(resp.Body)
+}
+
+// Los adaptadores piden JSON a hosts distintos del de la fuente (api.github.com
+// para gist.github.com), asi que -allow-hosts y -deny-hosts se comprueban aca
+func (vp *VerificadorProxies) descargarJSON(ctx context.Context, cliente *http.Client, direccion string, destino interface{}) error {
+	if err := vp.FuentePermitida(direccion); err != nil {
+		return err
+	}
+	cuerpo, err := vp.descargarTexto(ctx, cliente, direccion)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(cuerpo, destino)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Valores de flags de cada perfil de -profile. Las flags pasadas
+// explicitamente en la linea de comandos tienen prioridad
+var perfiles = map[string]map[string]string{
+	// Muchas conexiones y timeout corto: rapido, a costa de descartar proxies lentos
+	"fast-scan": {
+		"check":      "true",
+		"max-checks": "2000",
+		"timeout":    "2",
+	},
+	// Pocas conexiones, timeout largo y HTTP estricto: menos falsos negativos y positivos
+	"thorough": {
+		"check":       "true",
+		"max-checks":  "200",
+		"timeout":     "15",
+		"strict-http": "true",
+	},
+	// Validacion como consumidor real: pocas conexiones y timeout largo para que
+	// las solicitudes seguidas terminen; requiere -consumer-url
+	"consumer": {
+		"check":             "true",
+		"max-checks":        "100",
+		"timeout":           "10",
+		"consumer-requests": "4",
+		"user-agent":        agenteNavegador,
+	},
+	// Poco trafico y un User-Agent de navegador para no llamar la atencion de los objetivos
+	"stealth": {
+		"check":         "true",
+		"max-checks":    "50",
+		"timeout":       "10",
+		"max-bandwidth": "256K",
+		"user-agent":    agenteNavegador,
+	},
+}
+
+// Flags sin valor razonable por defecto que el perfil necesita que se pasen
+var flagsRequeridasPerfil = map[string][]string{
+	"consumer": {"consumer-url"},
+}
+
+// Nombres de los perfiles disponibles, ordenados
+func NombresPerfiles() []string {
+	var nombres []string
+	for nombre := range perfiles {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// Aplica un perfil a las flags ya parseadas sin pisar las que se pasaron explicitamente
+func AplicarPerfil(nombre string, flags *flag.FlagSet) error {
+	perfil, ok := perfiles[nombre]
+	if !ok {
+		return fmt.Errorf("-profile desconocido %q, opciones: %s", nombre, strings.Join(NombresPerfiles(), ", "))
+	}
+
+	explicitas := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicitas[f.Name] = true })
+	for _, requerida := range flagsRequeridasPerfil[nombre] {
+		if !explicitas[requerida] {
+			return fmt.Errorf("-profile %s necesita -%s", nombre, requerida)
+		}
+	}
+	for nombreFlag, valor := range perfil {
+		if explicitas[nombreFlag] {
+			continue
+		}
+		if err := flags.Set(nombreFlag, valor); err != nil {
+			return fmt.Errorf("perfil %s: -%s: %v", nombre, nombreFlag, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Fechas en que se vio un proxy en las fuentes
+type Avistamiento struct {
+	PrimeraVez time.Time `json:"first_seen"`
+	UltimaVez  time.Time `json:"last_seen"`
+}
+
+// Registro persistente de los proxies vistos en las fuentes, por tipo
+type RegistroVistos struct {
+	Ruta      string
+	Retencion time.Duration // se olvidan los proxies que no aparecen hace mas de este tiempo
+	Vistos    map[string]map[string]Avistamiento
+	mu        sync.Mutex
+}
+
+// Carga el registro desde disco; si no existe empieza vacio
+func CargarRegistroVistos(ruta string, retencion time.Duration) (*RegistroVistos, error) {
+	rv := &RegistroVistos{
+		Ruta:      ruta,
+		Retencion: retencion,
+		Vistos:    make(map[string]map[string]Avistamiento),
+	}
+
+	datos, err := os.ReadFile(ruta)
+	if os.IsNotExist(err) {
+		return rv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(datos, &rv.Vistos); err != nil {
+		return nil, fmt.Errorf("%s: %v", ruta, err)
+	}
+	return rv, nil
+}
+
+// Marca los proxies como vistos ahora, conservando la fecha de primera vez
+func (rv *RegistroVistos) Registrar(tipoProxy string, proxies []string, ahora time.Time) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	vistos := rv.Vistos[tipoProxy]
+	if vistos == nil {
+		vistos = make(map[string]Avistamiento)
+		rv.Vistos[tipoProxy] = vistos
+	}
+	for _, proxy := range proxies {
+		avistamiento, ok := vistos[proxy]
+		if !ok {
+			avistamiento.PrimeraVez = ahora
+		}
+		avistamiento.UltimaVez = ahora
+		vistos[proxy] = avistamiento
+	}
+}
+
+// Fecha en que se vio el proxy por primera vez
+func (rv *RegistroVistos) PrimeraVez(tipoProxy, proxy string) (time.Time, bool) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	avistamiento, ok := rv.Vistos[tipoProxy][proxy]
+	return avistamiento.PrimeraVez, ok
+}
+
+// Devuelve solo los proxies vistos por primera vez hace menos de maxEdad
+func (rv *RegistroVistos) FiltrarNuevos(tipoProxy string, proxies []string, maxEdad time.Duration, ahora time.Time) []string {
+	var nuevos []string
+	for _, proxy := range proxies {
+		primeraVez, ok := rv.PrimeraVez(tipoProxy, proxy)
+		if !ok || ahora.Sub(primeraVez) <= maxEdad {
+			nuevos = append(nuevos, proxy)
+		}
+	}
+	return nuevos
+}
+
+// Guarda el registro en disco descartando los proxies que ya no aparecen
+func (rv *RegistroVistos) Guardar(ahora time.Time) error {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	if rv.Retencion > 0 {
+		for _, vistos := range rv.Vistos {
+			for proxy, avistamiento := range vistos {
+				if ahora.Sub(avistamiento.UltimaVez) > rv.Retencion {
+					delete(vistos, proxy)
+				}
+			}
+		}
+	}
+
+	datos, err := json.Marshal(rv.Vistos)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(rv.Ruta), os.ModePerm)
+	temporal := rv.Ruta + ".tmp"
+	if err := os.WriteFile(temporal, datos, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporal, rv.Ruta)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+func SoltarPrivilegios(nombreUsuario, dirChroot string) error {
+	if nombreUsuario != "" || dirChroot != "" {
+		return errors.New("-user y -chroot solo estan disponibles en sistemas unix")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// Encierra el proceso en dirChroot y cambia al usuario indicado. Se llama
+// despues de cargar configuracion y bases y de abrir los puertos de escucha,
+// antes de contactar proxies
+func SoltarPrivilegios(nombreUsuario, dirChroot string) error {
+	var uid, gid int
+	if nombreUsuario != "" {
+		u, err := user.Lookup(nombreUsuario)
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return fmt.Errorf("uid invalido %q", u.Uid)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return fmt.Errorf("gid invalido %q", u.Gid)
+		}
+	}
+
+	if dirChroot != "" {
+		// El resolver de Go vuelve a leer /etc/resolv.conf mientras corre y dentro
+		// del chroot solo ve la copia del directorio; sin ella no resuelve nombres
+		if _, err := os.Stat(filepath.Join(dirChroot, "etc", "resolv.conf")); err != nil {
+			return fmt.Errorf("chroot %s: falta etc/resolv.conf (copia /etc/resolv.conf dentro del directorio): %v", dirChroot, err)
+		}
+		// Los certificados del sistema no estaran dentro del chroot
+		x509.SystemCertPool()
+		if err := syscall.Chroot(dirChroot); err != nil {
+			return fmt.Errorf("chroot %s: %v", dirChroot, err)
+		}
+		if err := os.Chdir("/"); err != nil {
+			return err
+		}
+	}
+
+	if nombreUsuario != "" {
+		if err := syscall.Setgroups([]int{gid}); err != nil {
+			return fmt.Errorf("setgroups: %v", err)
+		}
+		if err := syscall.Setgid(gid); err != nil {
+			return fmt.Errorf("setgid: %v", err)
+		}
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("setuid: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// Expone en direccion un endpoint /metrics en formato de texto de Prometheus con
+// el uso de recursos del proceso y los contadores de verificacion
+func (vp *VerificadorProxies) ServirMetricas(direccion string, metricas *MetricasVerificacion) (*http.Server, error) {
+	listener, err := net.Listen("tcp", direccion)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		estado := vp.EstadoProceso()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		escribir := func(nombre, tipo, ayuda string, valor interface{}) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", nombre, ayuda, nombre, tipo, nombre, valor)
+		}
+		escribir("ultraproxy_goroutines", "gauge", "Gorutinas en ejecucion.", estado.Gorutinas)
+		escribir("ultraproxy_open_sockets", "gauge", "Conexiones abiertas por el verificador sin cerrar.", estado.Sockets)
+		escribir("ultraproxy_heap_bytes", "gauge", "Bytes en objetos del heap.", estado.Heap)
+		if metricas != nil {
+			escribir("ultraproxy_checks_total", "counter", "Verificaciones hechas, incluidos reintentos.", atomic.LoadInt64(&metricas.Verificaciones))
+			escribir("ultraproxy_working_total", "counter", "Verificaciones con resultado funcional.", atomic.LoadInt64(&metricas.Funcionales))
+			escribir("ultraproxy_silent_total", "counter", "Verificaciones en las que el proxy no contesto.", atomic.LoadInt64(&metricas.SinRespuesta))
+		}
+	})
+
+	servidor := &http.Server{Handler: mux}
+	go servidor.Serve(listener)
+	return servidor, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Paginas que se piden como maximo a una API paginada
+const maxPaginasProveedor = 100
+
+const (
+	maxDescarga    = 64 << 20  // bytes que se leen como maximo de una fuente descargada
+	maxArchivoZip  = 64 << 20  // bytes que se leen como maximo de cada archivo de un zip
+	maxTotalZip    = 256 << 20 // bytes descomprimidos como maximo entre todos los archivos de un zip
+	maxArchivosZip = 1000      // archivos que puede tener un zip descargado
+)
+
+// Clave de API de la fuente, leida de la variable de entorno si se indico una
+func (f Fuente) claveAPI() string {
+	if f.VariableClaveAPI != "" {
+		return os.Getenv(f.VariableClaveAPI)
+	}
+	return f.ClaveAPI
+}
+
+// Lee los proxies asignados a una cuenta de un proveedor con API:
+// "webshare" (lista paginada en JSON), "proxyscrape" (lista con ?auth=) o
+// "api" (texto plano con la clave en una cabecera)
+func (vp *VerificadorProxies) LeerProveedor(ctx context.Context, cliente *http.Client, direccion string, fuente Fuente) ([]string, error) {
+	clave := fuente.claveAPI()
+	if clave == "" {
+		return nil, fmt.Errorf("fuente %s sin api_key", fuente.Tipo)
+	}
+
+	switch fuente.Tipo {
+	case "webshare":
+		return vp.leerWebshare(ctx, cliente, direccion, clave)
+
+	case "proxyscrape":
+		u, err := url.Parse(direccion)
+		if err != nil {
+			return nil, err
+		}
+		query := u.Query()
+		query.Set("auth", clave)
+		u.RawQuery = query.Encode()
+		cuerpo, err := vp.descargarTexto(ctx, cliente, u.String())
+		if err != nil {
+			return nil, err
+		}
+		return LineasDeArchivo(cuerpo)
+
+	default:
+		cabeceras := make(http.Header)
+		if fuente.CabeceraClaveAPI != "" {
+			cabeceras.Set(fuente.CabeceraClaveAPI, clave)
+		} else {
+			cabeceras.Set("Authorization", "Bearer "+clave)
+		}
+		cuerpo, err := vp.descargarConCabeceras(ctx, cliente, direccion, cabeceras)
+		if err != nil {
+			return nil, err
+		}
+		return LineasDeArchivo(cuerpo)
+	}
+}
+
+// Pagina de la lista de proxies de Webshare (API v2)
+type paginaWebshare struct {
+	Siguiente *string `json:"next"`
+	Proxies   []struct {
+		Direccion string `json:"proxy_address"`
+		Puerto    int    `json:"port"`
+		Usuario   string `json:"username"`
+		Clave     string `json:"password"`
+		Valido    bool   `json:"valid"`
+	} `json:"results"`
+}
+
+func (vp *VerificadorProxies) leerWebshare(ctx context.Context, cliente *http.Client, direccion, clave string) ([]string, error) {
+	cabeceras := http.Header{"Authorization": {"Token " + clave}}
+	origen, err := url.Parse(direccion)
+	if err != nil {
+		return nil, err
+	}
+	var lineas []string
+	for pagina := 0; direccion != "" && pagina < maxPaginasProveedor; pagina++ {
+		cuerpo, err := vp.descargarConCabeceras(ctx, cliente, direccion, cabeceras)
+		if err != nil {
+			return nil, err
+		}
+		var respuesta paginaWebshare
+		if err := json.Unmarshal(cuerpo, &respuesta); err != nil {
+			return nil, fmt.Errorf("webshare: %v", err)
+		}
+		for _, proxy := range respuesta.Proxies {
+			if !proxy.Valido {
+				continue
+			}
+			linea := fmt.Sprintf("%s:%d", proxy.Direccion, proxy.Puerto)
+			if proxy.Usuario != "" {
+				linea = fmt.Sprintf("%s:%s@%s", proxy.Usuario, proxy.Clave, linea)
+			}
+			lineas = append(lineas, linea)
+		}
+
+		direccion = ""
+		if respuesta.Siguiente != nil && *respuesta.Siguiente != "" {
+			// La clave va en cada pagina: "next" solo se sigue si apunta al mismo host
+			siguiente, err := origen.Parse(*respuesta.Siguiente)
+			if err != nil {
+				return nil, fmt.Errorf("webshare: pagina siguiente invalida: %v", err)
+			}
+			if siguiente.Scheme != origen.Scheme || siguiente.Host != origen.Host {
+				return nil, fmt.Errorf("webshare: la pagina siguiente %s no esta en %s", siguiente.Redacted(), origen.Host)
+			}
+			direccion = siguiente.String()
+		}
+	}
+	return lineas, nil
+}
+
+// Lineas de un archivo descargado. Los zip (listas "del dia" de los
+// proveedores) se descomprimen y se juntan sus archivos de texto
+func LineasDeArchivo(cuerpo []byte) ([]string, error) {
+	if !bytes.HasPrefix(cuerpo, []byte("PK\x03\x04")) {
+		return strings.Split(string(cuerpo), "\n"), nil
+	}
+
+	archivoZip, err := zip.NewReader(bytes.NewReader(cuerpo), int64(len(cuerpo)))
+	if err != nil {
+		return nil, err
+	}
+	if len(archivoZip.File) > maxArchivosZip {
+		return nil, fmt.Errorf("zip: tiene %d archivos, el maximo es %d", len(archivoZip.File), maxArchivosZip)
+	}
+	var lineas []string
+	restante := int64(maxTotalZip)
+	for _, archivo := range archivoZip.File {
+		switch strings.ToLower(path.Ext(archivo.Name)) {
+		case ".txt", ".csv", ".lst", "":
+		default:
+			continue
+		}
+		contenido, err := archivo.Open()
+		if err != nil {
+			return nil, err
+		}
+		limite := int64(maxArchivoZip)
+		if restante < limite {
+			limite = restante
+		}
+		datos, err := io.ReadAll(io.LimitReader(contenido, limite+1))
+		contenido.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(datos)) > limite {
+			if limite < maxArchivoZip {
+				return nil, fmt.Errorf("zip: el contenido descomprimido supera %d bytes", maxTotalZip)
+			}
+			return nil, fmt.Errorf("zip: %s supera %d bytes", archivo.Name, maxArchivoZip)
+		}
+		restante -= int64(len(datos))
+		lineas = append(lineas, strings.Split(string(datos), "\n")...)
+	}
+	return lineas, nil
+}
+
+// Lee el cuerpo de una descarga con el limite de maxDescarga, para que una
+// fuente enorme o que no termina no agote la memoria
+func leerDescarga(cuerpo io.Reader) ([]byte, error) {
+	datos, err := io.ReadAll(io.LimitReader(cuerpo, maxDescarga+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(datos) > maxDescarga {
+		return nil, fmt.Errorf("la descarga supera %d bytes", maxDescarga)
+	}
+	return datos, nil
+}
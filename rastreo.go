@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxPaginasRastreo     = 50 // paginas leidas como maximo por fuente rastreada
+	maxProfundidadRastreo = 3
+)
+
+var enlaceHTML = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)`)
+
+// Sitemap con URLs o indice de sitemaps
+type sitemapXML struct {
+	URLs     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+type paginaRastreo struct {
+	direccion string
+	nivel     int
+}
+
+// Recorre en anchura una pagina indice (o sitemap) sin salir de su host y junta
+// las lineas de las listas .txt enlazadas y los proxies de las tablas HTML
+func (vp *VerificadorProxies) Rastrear(ctx context.Context, cliente *http.Client, direccion string, profundidad int) ([]string, error) {
+	if profundidad <= 0 {
+		profundidad = 1
+	}
+	if profundidad > maxProfundidadRastreo {
+		profundidad = maxProfundidadRastreo
+	}
+	raiz, err := url.Parse(direccion)
+	if err != nil {
+		return nil, err
+	}
+
+	var lineas []string
+	visitadas := map[string]bool{direccion: true}
+	cola := []paginaRastreo{{direccion, 0}}
+	leidas := 0
+	for len(cola) > 0 && leidas < maxPaginasRastreo {
+		if ctx.Err() != nil {
+			break
+		}
+		pagina := cola[0]
+		cola = cola[1:]
+
+		cuerpo, err := vp.descargarTexto(ctx, cliente, pagina.direccion)
+		if err != nil {
+			if pagina.nivel == 0 {
+				return nil, err
+			}
+			vp.Log("WARNING", fmt.Sprintf("No se pudo leer %s al rastrear %s: %v", pagina.direccion, direccion, err))
+			continue
+		}
+		leidas++
+
+		base, _ := url.Parse(pagina.direccion)
+		var enlaces []string
+		switch {
+		case esSitemap(cuerpo):
+			var sitemap sitemapXML
+			if err := xml.Unmarshal(cuerpo, &sitemap); err == nil {
+				enlaces = append(sitemap.URLs, sitemap.Sitemaps...)
+			}
+		case strings.HasSuffix(strings.ToLower(base.Path), ".txt") || !bytes.ContainsRune(cuerpo, '<'):
+			lineas = append(lineas, strings.Split(string(cuerpo), "\n")...)
+			continue
+		default:
+			lineas = append(lineas, ExtraerProxiesDeTexto(string(cuerpo))...)
+			for _, coincidencia := range enlaceHTML.FindAllStringSubmatch(string(cuerpo), -1) {
+				enlaces = append(enlaces, coincidencia[1])
+			}
+		}
+
+		for _, enlace := range enlaces {
+			destino, err := base.Parse(strings.TrimSpace(enlace))
+			if err != nil || !strings.EqualFold(destino.Hostname(), raiz.Hostname()) {
+				continue
+			}
+			destino.Fragment = ""
+			siguiente := destino.String()
+			if visitadas[siguiente] {
+				continue
+			}
+			// Las listas .txt y los sitemaps se leen aunque esten en el ultimo nivel
+			final := strings.HasSuffix(strings.ToLower(path.Ext(destino.Path)), ".txt") || strings.HasSuffix(strings.ToLower(destino.Path), ".xml")
+			if pagina.nivel >= profundidad && !final {
+				continue
+			}
+			if err := vp.FuentePermitida(siguiente); err != nil {
+				continue
+			}
+			visitadas[siguiente] = true
+			cola = append(cola, paginaRastreo{siguiente, pagina.nivel + 1})
+		}
+	}
+	vp.Log("INFO", fmt.Sprintf("Rastreo de %s: %d paginas leidas", direccion, leidas))
+	return lineas, nil
+}
+
+func esSitemap(cuerpo []byte) bool {
+	inicio := cuerpo
+	if len(inicio) > 512 {
+		inicio = inicio[:512]
+	}
+	return bytes.Contains(inicio, []byte("<urlset")) || bytes.Contains(inicio, []byte("<sitemapindex"))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Abre una conexion TCP aplicando los limites de recursos configurados
+func (vp *VerificadorProxies) Conectar(ctx context.Context, red, direccion string) (net.Conn, error) {
+	marcar := vp.Dialer
+	if marcar == nil {
+		dialer := net.Dialer{Timeout: vp.Timeout, Control: vp.ControlConexion}
+		marcar = dialer.DialContext
+	}
+	inicio := time.Now()
+	conexion, err := marcar(ctx, red, direccion)
+	if err != nil {
+		if vp.Trazas[direccion] {
+			vp.Log("TRACE", fmt.Sprintf("%s no se pudo conectar (+%s): %v", direccion, redondear(time.Since(inicio)), err))
+		}
+		return nil, err
+	}
+	atomic.AddInt64(&vp.socketsAbiertos, 1)
+	conexion = &conexionContada{Conn: conexion, contador: &vp.socketsAbiertos}
+	if vp.Trazas[direccion] {
+		conexion = vp.trazar(conexion, direccion, inicio)
+	}
+	if vp.LimiteAncho != nil {
+		conexion = &conexionLimitada{Conn: conexion, limitador: vp.LimiteAncho}
+	}
+	return conexion, nil
+}
+
+// Token bucket sobre bytes: permite hasta BytesPorSegundo de media con
+// rafagas de hasta un segundo
+type LimitadorBytes struct {
+	BytesPorSegundo int64
+	mu              sync.Mutex
+	disponibles     float64
+	ultimo          time.Time
+}
+
+func NuevoLimitadorBytes(bytesPorSegundo int64) *LimitadorBytes {
+	return &LimitadorBytes{
+		BytesPorSegundo: bytesPorSegundo,
+		disponibles:     float64(bytesPorSegundo),
+		ultimo:          time.Now(),
+	}
+}
+
+// Consume n bytes del presupuesto, durmiendo si hace falta. El saldo puede
+// quedar negativo para que lecturas ya hechas se paguen con espera posterior
+func (lb *LimitadorBytes) Consumir(n int) {
+	lb.mu.Lock()
+	ahora := time.Now()
+	lb.disponibles += ahora.Sub(lb.ultimo).Seconds() * float64(lb.BytesPorSegundo)
+	if lb.disponibles > float64(lb.BytesPorSegundo) {
+		lb.disponibles = float64(lb.BytesPorSegundo)
+	}
+	lb.ultimo = ahora
+	lb.disponibles -= float64(n)
+	deuda := -lb.disponibles
+	lb.mu.Unlock()
+
+	if deuda > 0 {
+		time.Sleep(time.Duration(deuda / float64(lb.BytesPorSegundo) * float64(time.Second)))
+	}
+}
+
+type conexionLimitada struct {
+	net.Conn
+	limitador *LimitadorBytes
+}
+
+func (cl *conexionLimitada) Read(b []byte) (int, error) {
+	n, err := cl.Conn.Read(b)
+	if n > 0 {
+		cl.limitador.Consumir(n)
+	}
+	return n, err
+}
+
+func (cl *conexionLimitada) Write(b []byte) (int, error) {
+	cl.limitador.Consumir(len(b))
+	return cl.Conn.Write(b)
+}
+
+// Descuenta la conexion de los sockets abiertos al cerrarla, una sola vez
+type conexionContada struct {
+	net.Conn
+	contador *int64
+	cerrada  sync.Once
+}
+
+func (cc *conexionContada) Close() error {
+	cc.cerrada.Do(func() { atomic.AddInt64(cc.contador, -1) })
+	return cc.Conn.Close()
+}
+
+// Uso de recursos del proceso, para el progreso y -metrics-addr
+type EstadoProceso struct {
+	Gorutinas int
+	Sockets   int64  // conexiones abiertas por Conectar que siguen sin cerrar
+	Heap      uint64 // bytes en objetos del heap
+}
+
+func (vp *VerificadorProxies) EstadoProceso() EstadoProceso {
+	muestra := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(muestra)
+	return EstadoProceso{
+		Gorutinas: runtime.NumGoroutine(),
+		Sockets:   atomic.LoadInt64(&vp.socketsAbiertos),
+		Heap:      muestra[0].Value.Uint64(),
+	}
+}
+
+func (ep EstadoProceso) String() string {
+	return fmt.Sprintf("gorutinas %d, sockets %d, heap %.1f MB", ep.Gorutinas, ep.Sockets, float64(ep.Heap)/(1<<20))
+}
+
+// Convierte "512K", "2M" o "1000" en bytes
+func ParsearTamano(valor string) (int64, error) {
+	valor = strings.ToUpper(strings.TrimSpace(valor))
+	multiplicador := int64(1)
+	switch {
+	case strings.HasSuffix(valor, "K"):
+		multiplicador, valor = 1<<10, strings.TrimSuffix(valor, "K")
+	case strings.HasSuffix(valor, "M"):
+		multiplicador, valor = 1<<20, strings.TrimSuffix(valor, "M")
+	case strings.HasSuffix(valor, "G"):
+		multiplicador, valor = 1<<30, strings.TrimSuffix(valor, "G")
+	}
+	numero, err := strconv.ParseInt(valor, 10, 64)
+	if err != nil || numero <= 0 {
+		return 0, fmt.Errorf("tamano invalido %q", valor)
+	}
+	return numero * multiplicador, nil
+}
+
+// Muestrea el heap en segundo plano e indica cuando supera MemoriaMaxima
+type PresionMemoria struct {
+	alta    atomic.Bool
+	detener chan struct{}
+}
+
+func (vp *VerificadorProxies) VigilarMemoria() *PresionMemoria {
+	pm := &PresionMemoria{detener: make(chan struct{})}
+	if vp.MemoriaMaxima == 0 {
+		return pm
+	}
+
+	muestra := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pm.detener:
+				return
+			case <-ticker.C:
+				metrics.Read(muestra)
+				pm.alta.Store(muestra[0].Value.Uint64() > vp.MemoriaMaxima)
+			}
+		}
+	}()
+	return pm
+}
+
+// Bloquea mientras el heap este por encima del objetivo
+func (pm *PresionMemoria) Esperar(ctx context.Context) {
+	for pm.alta.Load() && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (pm *PresionMemoria) Detener() {
+	close(pm.detener)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type rangoASN struct {
+	rangoIPv4
+	ASN         string
+	Descripcion string
+}
+
+// Base IP -> ASN cargada desde ip2asn-v4.tsv (iptoasn.com) o un CSV equivalente
+type BaseASN struct {
+	rangos []rangoASN
+}
+
+// Carga un TSV/CSV con columnas inicio,fin,asn[,pais,descripcion]
+func CargarBaseASN(ruta string) (*BaseASN, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, err
+	}
+	defer archivo.Close()
+
+	lectorBufferizado := bufio.NewReader(archivo)
+	lector := csv.NewReader(lectorBufferizado)
+	if primera, _ := lectorBufferizado.Peek(256); strings.Contains(string(primera), "\t") {
+		lector.Comma = '\t'
+	}
+	lector.FieldsPerRecord = -1
+	lector.LazyQuotes = true
+
+	base := &BaseASN{}
+	for {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ruta, err)
+		}
+		if len(registro) < 3 {
+			continue
+		}
+		inicio, ok1 := parsearExtremoRango(registro[0])
+		fin, ok2 := parsearExtremoRango(registro[1])
+		asn := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(registro[2])), "AS")
+		if !ok1 || !ok2 || asn == "" || asn == "0" {
+			continue
+		}
+		rango := rangoASN{rangoIPv4: rangoIPv4{inicio, fin}, ASN: "AS" + asn}
+		if len(registro) >= 5 {
+			rango.Descripcion = strings.TrimSpace(registro[4])
+		}
+		base.rangos = append(base.rangos, rango)
+	}
+
+	sort.Slice(base.rangos, func(i, j int) bool { return base.rangos[i].Inicio < base.rangos[j].Inicio })
+	return base, nil
+}
+
+// ASN y descripcion de la IP de un proxy, o "" si no se conoce
+func (ba *BaseASN) Buscar(proxy string) (string, string) {
+	host, _, err := net.SplitHostPort(DireccionProxy(proxy))
+	if err != nil {
+		return "", ""
+	}
+	numero, ok := ipv4ANumero(net.ParseIP(host))
+	if !ok {
+		return "", ""
+	}
+	i := sort.Search(len(ba.rangos), func(i int) bool { return ba.rangos[i].Inicio > numero })
+	if i > 0 && ba.rangos[i-1].Fin >= numero {
+		return ba.rangos[i-1].ASN, ba.rangos[i-1].Descripcion
+	}
+	return "", ""
+}
+
+// Red /24 de la IP de un proxy, o "" si no es IPv4
+func Subred24(proxy string) string {
+	host, _, err := net.SplitHostPort(DireccionProxy(proxy))
+	if err != nil {
+		return ""
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+}
+
+type conteoRed struct {
+	Red      string
+	Proxies  int
+	Detalles string
+}
+
+// Cuenta proxies por clave y ordena de mayor a menor
+func contarPorRed(proxies []string, clave func(string) (string, string)) []conteoRed {
+	conteo := make(map[string]*conteoRed)
+	for _, proxy := range proxies {
+		red, detalles := clave(proxy)
+		if red == "" {
+			red = "desconocido"
+		}
+		if conteo[red] == nil {
+			conteo[red] = &conteoRed{Red: red, Detalles: detalles}
+		}
+		conteo[red].Proxies++
+	}
+
+	lista := make([]conteoRed, 0, len(conteo))
+	for _, c := range conteo {
+		lista = append(lista, *c)
+	}
+	sort.Slice(lista, func(i, j int) bool {
+		if lista[i].Proxies != lista[j].Proxies {
+			return lista[i].Proxies > lista[j].Proxies
+		}
+		return lista[i].Red < lista[j].Red
+	})
+	return lista
+}
+
+// Guarda proxies/<TIPO>_networks.txt con los proxies funcionales por /24 y por ASN
+func (vp *VerificadorProxies) GuardarReporteRedes(tipoProxy string, proxies []string) {
+	dirFinal := "proxies"
+	os.MkdirAll(dirFinal, os.ModePerm)
+	rutaFinal := fmt.Sprintf("%s/%s_networks.txt", dirFinal, strings.ToUpper(tipoProxy))
+
+	archivo, err := os.Create(rutaFinal)
+	if err != nil {
+		vp.Log("ERROR", fmt.Sprintf("No se pudo guardar el reporte de redes %s: %v", tipoProxy, err))
+		return
+	}
+	defer archivo.Close()
+
+	subredes := contarPorRed(proxies, func(proxy string) (string, string) { return Subred24(proxy), "" })
+	escritor := bufio.NewWriter(archivo)
+	fmt.Fprintf(escritor, "# run_id %s\n# %d proxies %s\n\n# /24 proxies\n", vp.IDEjecucion, len(proxies), tipoProxy)
+	for _, subred := range subredes {
+		fmt.Fprintf(escritor, "%s %d\n", subred.Red, subred.Proxies)
+	}
+
+	resumen := fmt.Sprintf("%d proxies %s en %d redes /24", len(proxies), tipoProxy, len(subredes))
+	if vp.ASN != nil {
+		sistemas := contarPorRed(proxies, vp.ASN.Buscar)
+		fmt.Fprintf(escritor, "\n# ASN proxies descripcion\n")
+		for _, sistema := range sistemas {
+			fmt.Fprintf(escritor, "%s %d %s\n", sistema.Red, sistema.Proxies, sistema.Detalles)
+		}
+		resumen += fmt.Sprintf(" y %d ASN", len(sistemas))
+	}
+	escritor.Flush()
+	vp.Log("INFO", fmt.Sprintf("%s, reporte en %s", resumen, rutaFinal))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Parametros de la prueba de resistencia (-soak)
+type ConfigResistencia struct {
+	URL         string        // objetivo que se pide a traves de los proxies
+	Tasa        float64       // solicitudes por segundo
+	Umbral      float64       // fraccion de errores en la ventana que termina la prueba
+	Ventana     time.Duration // periodo sobre el que se calcula la fraccion de errores
+	DuracionMax time.Duration // 0 = hasta superar el umbral o quedarse sin proxies
+}
+
+// Proxy del pool verificado junto con su tipo
+type ProxyTipado struct {
+	Tipo  string
+	Proxy string
+}
+
+// Fallos seguidos tras los que un proxy sale de la rotacion
+const fallosParaDescartar = 3
+
+// Muestras minimas en la ventana antes de evaluar el umbral
+const muestrasMinimasVentana = 20
+
+// Resultado de la prueba de resistencia
+type ReporteResistencia struct {
+	Duracion    time.Duration
+	Solicitudes int
+	Errores     int
+	Proxies     int
+	Descartados int
+	Motivo      string
+}
+
+// Lee proxies/<TIPO>_verified.txt de cada tipo como pool verificado. No usa
+// proxies/<TIPO>.txt: sin -check ese archivo tiene proxies sin verificar
+func CargarPoolVerificado() []ProxyTipado {
+	var pool []ProxyTipado
+	for _, tipoProxy := range []string{"socks4", "socks5", "http"} {
+		var proxies []string
+		for proxy := range LeerListaProxies(RutaListaVerificada(tipoProxy)) {
+			proxies = append(proxies, proxy)
+		}
+		sort.Strings(proxies)
+		for _, proxy := range proxies {
+			pool = append(pool, ProxyTipado{Tipo: tipoProxy, Proxy: proxy})
+		}
+	}
+	return pool
+}
+
+// Estado compartido de la prueba
+type estadoResistencia struct {
+	mu        sync.Mutex
+	muestras  []muestraResistencia
+	fallos    map[ProxyTipado]int
+	activos   []ProxyTipado
+	siguiente int
+	reporte   ReporteResistencia
+}
+
+type muestraResistencia struct {
+	momento time.Time
+	ok      bool
+}
+
+// Proximo proxy en rotacion, false si no queda ninguno
+func (er *estadoResistencia) tomar() (ProxyTipado, bool) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	if len(er.activos) == 0 {
+		return ProxyTipado{}, false
+	}
+	er.siguiente %= len(er.activos)
+	proxy := er.activos[er.siguiente]
+	er.siguiente++
+	return proxy, true
+}
+
+// Registra una solicitud y devuelve la fraccion de errores en la ventana
+func (er *estadoResistencia) registrar(proxy ProxyTipado, ok bool, ventana time.Duration) (float64, int) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	ahora := time.Now()
+	er.reporte.Solicitudes++
+	er.muestras = append(er.muestras, muestraResistencia{momento: ahora, ok: ok})
+	if ok {
+		er.fallos[proxy] = 0
+	} else {
+		er.reporte.Errores++
+		er.fallos[proxy]++
+		if er.fallos[proxy] == fallosParaDescartar {
+			for i, activo := range er.activos {
+				if activo == proxy {
+					er.activos = append(er.activos[:i], er.activos[i+1:]...)
+					er.reporte.Descartados++
+					break
+				}
+			}
+		}
+	}
+
+	inicio := 0
+	for inicio < len(er.muestras) && ahora.Sub(er.muestras[inicio].momento) > ventana {
+		inicio++
+	}
+	er.muestras = er.muestras[inicio:]
+	errores := 0
+	for _, muestra := range er.muestras {
+		if !muestra.ok {
+			errores++
+		}
+	}
+	return float64(errores) / float64(len(er.muestras)), len(er.muestras)
+}
+
+// Una solicitud a config.URL a traves del proxy. Cuenta como exito una respuesta
+// menor a 400 que ademas cumpla las aserciones de -assert si hay
+func (vp *VerificadorProxies) solicitudResistencia(ctx context.Context, proxy ProxyTipado, direccion string) bool {
+	ctx, cancelar := context.WithTimeout(ctx, 2*vp.Timeout)
+	defer cancelar()
+
+	resp, err := vp.GetAtravesDe(ctx, proxy.Tipo, proxy.Proxy, direccion)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	cuerpo, _ := io.ReadAll(io.LimitReader(resp.Body, maxCuerpoAsercion))
+	if resp.StatusCode >= 400 {
+		return false
+	}
+	for _, asercion := range vp.Aserciones {
+		if !asercion.Evaluar(resp, cuerpo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Consume el pool a config.Tasa solicitudes por segundo contra config.URL y mide
+// cuanto tiempo aguanta antes de que los errores superen config.Umbral
+func (vp *VerificadorProxies) PruebaResistencia(ctx context.Context, pool []ProxyTipado, config ConfigResistencia, maxConcurrentes int) ReporteResistencia {
+	estado := &estadoResistencia{
+		fallos:  make(map[ProxyTipado]int),
+		activos: append([]ProxyTipado(nil), pool...),
+	}
+	estado.reporte.Proxies = len(pool)
+
+	prueba, detener := context.WithCancel(ctx)
+	defer detener()
+	if config.DuracionMax > 0 {
+		prueba, detener = context.WithTimeout(prueba, config.DuracionMax)
+		defer detener()
+	}
+	var motivo sync.Once
+	terminar := func(texto string) {
+		motivo.Do(func() { estado.reporte.Motivo = texto })
+		detener()
+	}
+
+	tokens := make(chan struct{}, vp.trabajadores(maxConcurrentes))
+	var wg sync.WaitGroup
+	inicio := time.Now()
+	intervalo := time.Duration(float64(time.Second) / config.Tasa)
+	if intervalo <= 0 {
+		intervalo = time.Nanosecond
+	}
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	ultimoLog := inicio
+bucle:
+	for {
+		select {
+		case <-prueba.Done():
+			break bucle
+		case <-ticker.C:
+		}
+		proxy, ok := estado.tomar()
+		if !ok {
+			terminar("todos los proxies fueron descartados")
+			break bucle
+		}
+		// Con maxConcurrentes solicitudes en curso se espera a que termine alguna
+		select {
+		case tokens <- struct{}{}:
+		case <-prueba.Done():
+			break bucle
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-tokens }()
+			ok := vp.solicitudResistencia(prueba, proxy, config.URL)
+			if prueba.Err() != nil {
+				return
+			}
+			errores, muestras := estado.registrar(proxy, ok, config.Ventana)
+			if muestras >= muestrasMinimasVentana && errores > config.Umbral {
+				terminar(fmt.Sprintf("errores en la ventana de %s: %.0f%% > %.0f%%", config.Ventana, errores*100, config.Umbral*100))
+			}
+		}()
+
+		if time.Since(ultimoLog) >= 10*time.Second {
+			ultimoLog = time.Now()
+			estado.mu.Lock()
+			vp.Log("INFO", fmt.Sprintf("Resistencia: %s, %d solicitudes, %d errores, %d proxies en rotacion",
+				time.Since(inicio).Round(time.Second), estado.reporte.Solicitudes, estado.reporte.Errores, len(estado.activos)))
+			estado.mu.Unlock()
+		}
+	}
+	detener()
+	wg.Wait()
+
+	estado.reporte.Duracion = time.Since(inicio)
+	if estado.reporte.Motivo == "" {
+		if ctx.Err() != nil {
+			estado.reporte.Motivo = "cancelada"
+		} else {
+			estado.reporte.Motivo = "se alcanzo -soak-duration sin superar el umbral"
+		}
+	}
+	return estado.reporte
+}
+
+// Escribe el reporte de la prueba de resistencia en el log
+func (vp *VerificadorProxies) LogReporteResistencia(reporte ReporteResistencia, config ConfigResistencia) {
+	vp.Log("INFO", fmt.Sprintf("Resistencia: el pool de %d proxies aguanto %s a %.1f solicitudes/s (%s)",
+		reporte.Proxies, reporte.Duracion.Round(time.Second), config.Tasa, reporte.Motivo))
+	vp.Log("INFO", fmt.Sprintf("Resistencia: %d solicitudes, %d errores, %d proxies descartados tras %d fallos seguidos",
+		reporte.Solicitudes, reporte.Errores, reporte.Descartados, fallosParaDescartar))
+	if reporte.Descartados > 0 {
+		porProxy := reporte.Duracion / time.Duration(reporte.Descartados)
+		vp.Log("INFO", fmt.Sprintf("Resistencia: se pierde un proxy cada %s, unos %d por hora con esta carga",
+			porProxy.Round(time.Millisecond), int(time.Hour/porProxy)))
+	}
+}
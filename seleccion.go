@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proxy del pool con los datos que usan las estrategias de seleccion
+type CandidatoSeleccion struct {
+	ProxyTipado
+	Latencia   time.Duration // ultima latencia del historial, 0 si no se conoce
+	Exito      float64       // fraccion de verificaciones funcionales en el historial, 1 sin historial
+	Verificado time.Time     // ultima verificacion conocida
+	Puntaje    float64       // Exito con el decaimiento por el tiempo desde Verificado
+	Usos       int64
+	UltimoUso  time.Time
+}
+
+// Factor por el que se multiplica Exito: se reduce a la mitad cada vidaMedia
+// sin verificar, asi un proxy que funcionaba ayer baja frente a uno recien
+// verificado sin tener que volver a verificar todo el pool
+func decaimiento(desde, ahora time.Time, vidaMedia time.Duration) float64 {
+	if vidaMedia <= 0 || desde.IsZero() || !ahora.After(desde) {
+		return 1
+	}
+	return math.Pow(0.5, float64(ahora.Sub(desde))/float64(vidaMedia))
+}
+
+// Elige uno de los candidatos (nunca vacio) y devuelve su indice. El selector
+// llama a Elegir con su lock tomado, asi que una estrategia puede guardar estado
+type EstrategiaSeleccion interface {
+	Elegir(candidatos []*CandidatoSeleccion) int
+}
+
+// Adapta una funcion a EstrategiaSeleccion
+type FuncionEstrategia func(candidatos []*CandidatoSeleccion) int
+
+func (f FuncionEstrategia) Elegir(candidatos []*CandidatoSeleccion) int {
+	return f(candidatos)
+}
+
+// Latencia que se asume para candidatos sin latencia conocida
+const latenciaDesconocida = time.Second
+
+// Al azar con peso puntaje/latencia: los proxies confiables y rapidos salen
+// mas seguido sin que los demas dejen de salir
+func SeleccionPonderada(candidatos []*CandidatoSeleccion) int {
+	pesos := make([]float64, len(candidatos))
+	total := 0.0
+	for i, candidato := range candidatos {
+		latencia := candidato.Latencia
+		if latencia <= 0 {
+			latencia = latenciaDesconocida
+		}
+		if latencia < 10*time.Millisecond {
+			latencia = 10 * time.Millisecond
+		}
+		pesos[i] = candidato.Puntaje / latencia.Seconds()
+		total += pesos[i]
+	}
+	if total <= 0 {
+		return rand.Intn(len(candidatos))
+	}
+	r := rand.Float64() * total
+	for i, peso := range pesos {
+		if r < peso {
+			return i
+		}
+		r -= peso
+	}
+	return len(candidatos) - 1
+}
+
+// Cada candidato por turno, en el orden del pool. El turno se lleva por tipo:
+// pedir proxies http no saltea a ningun socks5 en la rotacion de ese tipo
+type RoundRobin struct {
+	siguiente map[string]int // por tipo de los candidatos; "" para el pool mezclado
+}
+
+func (rr *RoundRobin) Elegir(candidatos []*CandidatoSeleccion) int {
+	clave := candidatos[0].Tipo
+	for _, candidato := range candidatos[1:] {
+		if candidato.Tipo != clave {
+			clave = ""
+			break
+		}
+	}
+	if rr.siguiente == nil {
+		rr.siguiente = make(map[string]int)
+	}
+	i := rr.siguiente[clave] % len(candidatos)
+	rr.siguiente[clave] = i + 1
+	return i
+}
+
+// El candidato que hace mas tiempo no se usa; los nunca usados primero
+func SeleccionMenosReciente(candidatos []*CandidatoSeleccion) int {
+	elegido := 0
+	for i, candidato := range candidatos {
+		if candidato.UltimoUso.Before(candidatos[elegido].UltimoUso) {
+			elegido = i
+		}
+	}
+	return elegido
+}
+
+// Entrega proxies del pool segun una estrategia con nombre
+type SelectorProxies struct {
+	VidaMedia   time.Duration // decaimiento del puntaje, 0 = sin decaimiento
+	mu          sync.Mutex
+	candidatos  []*CandidatoSeleccion
+	estrategias map[string]EstrategiaSeleccion
+}
+
+// Error de Elegir cuando no queda ningun proxy del tipo pedido
+var errPoolVacio = errors.New("no hay proxies en el pool")
+
+// Estrategia que usa Elegir si no se indica otra
+const EstrategiaPorDefecto = "weighted"
+
+// Selector con las estrategias weighted, round_robin y least_recently_used
+func NuevoSelectorProxies(candidatos []CandidatoSeleccion) *SelectorProxies {
+	sp := &SelectorProxies{estrategias: map[string]EstrategiaSeleccion{}}
+	for i := range candidatos {
+		candidato := candidatos[i]
+		if candidato.Puntaje == 0 {
+			candidato.Puntaje = candidato.Exito
+		}
+		sp.candidatos = append(sp.candidatos, &candidato)
+	}
+	sp.RegistrarEstrategia("weighted", FuncionEstrategia(SeleccionPonderada))
+	sp.RegistrarEstrategia("round_robin", &RoundRobin{})
+	sp.RegistrarEstrategia("least_recently_used", FuncionEstrategia(SeleccionMenosReciente))
+	return sp
+}
+
+// Agrega o reemplaza una estrategia
+func (sp *SelectorProxies) RegistrarEstrategia(nombre string, estrategia EstrategiaSeleccion) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.estrategias[nombre] = estrategia
+}
+
+// Nombres de las estrategias registradas, ordenados
+func (sp *SelectorProxies) Estrategias() []string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	var nombres []string
+	for nombre := range sp.estrategias {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
+
+// Recalcula Puntaje con el decaimiento hasta ahora; se llama con el lock tomado
+func (sp *SelectorProxies) actualizarPuntajes(ahora time.Time) {
+	for _, candidato := range sp.candidatos {
+		candidato.Puntaje = candidato.Exito * decaimiento(candidato.Verificado, ahora, sp.VidaMedia)
+	}
+}
+
+// Candidatos del pool, opcionalmente solo de un tipo, de mayor a menor puntaje
+// y, a igual puntaje, de menor latencia
+func (sp *SelectorProxies) Listar(tipoProxy string) []CandidatoSeleccion {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.actualizarPuntajes(time.Now())
+	var lista []CandidatoSeleccion
+	for _, candidato := range sp.candidatos {
+		if tipoProxy == "" || candidato.Tipo == tipoProxy {
+			lista = append(lista, *candidato)
+		}
+	}
+	sort.SliceStable(lista, func(i, j int) bool {
+		if lista[i].Puntaje != lista[j].Puntaje {
+			return lista[i].Puntaje > lista[j].Puntaje
+		}
+		return lista[i].Latencia < lista[j].Latencia
+	})
+	return lista
+}
+
+// Elige un proxy con la estrategia indicada ("" = EstrategiaPorDefecto),
+// opcionalmente solo entre los de un tipo, y lo marca como usado
+func (sp *SelectorProxies) Elegir(estrategia, tipoProxy string) (CandidatoSeleccion, error) {
+	if estrategia == "" {
+		estrategia = EstrategiaPorDefecto
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	elegir, ok := sp.estrategias[estrategia]
+	if !ok {
+		var nombres []string
+		for nombre := range sp.estrategias {
+			nombres = append(nombres, nombre)
+		}
+		sort.Strings(nombres)
+		return CandidatoSeleccion{}, fmt.Errorf("estrategia desconocida %q, opciones: %s", estrategia, strings.Join(nombres, ", "))
+	}
+	candidatos := sp.candidatos
+	if tipoProxy != "" {
+		candidatos = nil
+		for _, candidato := range sp.candidatos {
+			if candidato.Tipo == tipoProxy {
+				candidatos = append(candidatos, candidato)
+			}
+		}
+	}
+	if len(candidatos) == 0 {
+		return CandidatoSeleccion{}, errPoolVacio
+	}
+	sp.actualizarPuntajes(time.Now())
+
+	candidato := candidatos[elegir.Elegir(candidatos)]
+	candidato.Usos++
+	candidato.UltimoUso = time.Now()
+	return *candidato, nil
+}
+
+// Pool verificado (proxies/<TIPO>_verified.txt, ver CargarPoolVerificado) con
+// exito, latencia y fecha de verificacion sacados del historial de -history, si
+// existe. Sin historial la fecha es la del archivo del pool
+func CargarCandidatosSeleccion(rutaHistorial string) ([]CandidatoSeleccion, error) {
+	type estadistica struct {
+		verificaciones, funcionales int
+		latencia                    time.Duration
+		fecha                       time.Time // de la ultima verificacion funcional
+		ultima                      time.Time
+	}
+	estadisticas := map[ProxyTipado]*estadistica{}
+
+	if rutaHistorial != "" {
+		archivo, err := os.Open(rutaHistorial)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			escaner := bufio.NewScanner(archivo)
+			escaner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for escaner.Scan() {
+				var registro RegistroHistorial
+				if json.Unmarshal(escaner.Bytes(), &registro) != nil {
+					continue
+				}
+				clave := ProxyTipado{Tipo: registro.Tipo, Proxy: registro.Direccion}
+				e := estadisticas[clave]
+				if e == nil {
+					e = &estadistica{}
+					estadisticas[clave] = e
+				}
+				e.verificaciones++
+				if registro.Fecha.After(e.ultima) {
+					e.ultima = registro.Fecha
+				}
+				if registro.Funcional {
+					e.funcionales++
+					if !registro.Fecha.Before(e.fecha) {
+						e.latencia = time.Duration(registro.Latencia) * time.Millisecond
+						e.fecha = registro.Fecha
+					}
+				}
+			}
+			archivo.Close()
+			if err := escaner.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fechasPool := make(map[string]time.Time)
+	var candidatos []CandidatoSeleccion
+	for _, proxy := range CargarPoolVerificado() {
+		fechaPool, ok := fechasPool[proxy.Tipo]
+		if !ok {
+			if info, err := os.Stat(RutaListaVerificada(proxy.Tipo)); err == nil {
+				fechaPool = info.ModTime()
+			}
+			fechasPool[proxy.Tipo] = fechaPool
+		}
+		candidato := CandidatoSeleccion{ProxyTipado: proxy, Exito: 1, Verificado: fechaPool}
+		if e := estadisticas[proxy]; e != nil {
+			candidato.Exito = float64(e.funcionales) / float64(e.verificaciones)
+			candidato.Latencia = e.latencia
+			if e.ultima.After(candidato.Verificado) {
+				candidato.Verificado = e.ultima
+			}
+		}
+		candidato.Puntaje = candidato.Exito
+		candidatos = append(candidatos, candidato)
+	}
+	return candidatos, nil
+}
+
+// Respuesta de GET /proxies/pick y cada elemento de GET /proxies
+type RespuestaSeleccion struct {
+	Proxy      string     `json:"proxy"`
+	Tipo       string     `json:"type"`
+	Latencia   int64      `json:"latency_ms,omitempty"`
+	Puntaje    float64    `json:"score"`
+	Verificado *time.Time `json:"checked_at,omitempty"`
+	Usos       int64      `json:"uses"`
+	Estrategia string     `json:"strategy,omitempty"`
+}
+
+func respuestaDeCandidato(candidato CandidatoSeleccion, estrategia string) RespuestaSeleccion {
+	respuesta := RespuestaSeleccion{
+		Proxy:      candidato.Proxy,
+		Tipo:       candidato.Tipo,
+		Latencia:   candidato.Latencia.Milliseconds(),
+		Puntaje:    candidato.Puntaje,
+		Usos:       candidato.Usos,
+		Estrategia: estrategia,
+	}
+	if !candidato.Verificado.IsZero() {
+		verificado := candidato.Verificado
+		respuesta.Verificado = &verificado
+	}
+	return respuesta
+}
+
+// Sirve en listener GET /proxies/pick?strategy=<estrategia>&type=<tipo>, que
+// devuelve un proxy del selector en JSON, y GET /proxies?sort=score&type=<tipo>,
+// que devuelve el pool ordenado por puntaje. El listener se abre antes de soltar
+// privilegios, para poder usar puertos bajos con -user
+func ServirSeleccion(listener net.Listener, selector *SelectorProxies) *http.Server {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proxies/pick", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "solo GET", http.StatusMethodNotAllowed)
+			return
+		}
+		estrategia := r.URL.Query().Get("strategy")
+		if estrategia == "" {
+			estrategia = EstrategiaPorDefecto
+		}
+		candidato, err := selector.Elegir(estrategia, r.URL.Query().Get("type"))
+		if err != nil {
+			codigo := http.StatusBadRequest
+			if errors.Is(err, errPoolVacio) {
+				codigo = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), codigo)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respuestaDeCandidato(candidato, estrategia))
+	})
+	mux.HandleFunc("/proxies", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "solo GET", http.StatusMethodNotAllowed)
+			return
+		}
+		if orden := r.URL.Query().Get("sort"); orden != "" && orden != "score" {
+			http.Error(w, fmt.Sprintf("orden desconocido %q, opciones: score", orden), http.StatusBadRequest)
+			return
+		}
+		lista := []RespuestaSeleccion{}
+		for _, candidato := range selector.Listar(r.URL.Query().Get("type")) {
+			lista = append(lista, respuestaDeCandidato(candidato, ""))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lista)
+	})
+
+	servidor := &http.Server{Handler: mux}
+	go servidor.Serve(listener)
+	return servidor
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Conexion que lee primero lo que quedo en el bufio.Reader de la respuesta CONNECT
+type conexionConLector struct {
+	net.Conn
+	lector *bufio.Reader
+}
+
+func (c conexionConLector) Read(b []byte) (int, error) {
+	return c.lector.Read(b)
+}
+
+// Abre un tunel CONNECT hacia -tls-target y hace el handshake TLS con ese
+// nombre como SNI, verificando que el certificado lo cubra. Devuelve "ok",
+// "captive" (certificado de otro host o no confiable, tipico de portales
+// cautivos e intercepcion), "redirecting" (responde HTTP en lugar de TLS o
+// una redireccion al CONNECT) o "failed"
+func (vp *VerificadorProxies) VerificarTLSHTTP(ctx context.Context, proxy string) string {
+	host, _, err := net.SplitHostPort(vp.ObjetivoTLS)
+	if err != nil {
+		return "failed"
+	}
+	ctx, cancelar := context.WithTimeout(ctx, vp.Timeout)
+	defer cancelar()
+
+	conexion, err := vp.Conectar(ctx, "tcp", DireccionProxy(proxy))
+	if err != nil {
+		return "failed"
+	}
+	defer conexion.Close()
+	conexion.SetDeadline(time.Now().Add(vp.Timeout))
+
+	var solicitudConnect strings.Builder
+	fmt.Fprintf(&solicitudConnect, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", vp.ObjetivoTLS, vp.ObjetivoTLS)
+	vp.CabecerasConnect.Write(&solicitudConnect)
+	if usuario, clave, ok := CredencialesProxy(proxy); ok {
+		fmt.Fprintf(&solicitudConnect, "Proxy-Authorization: Basic %s\r\n", base64.StdEncoding.EncodeToString([]byte(usuario+":"+clave)))
+	}
+	solicitudConnect.WriteString("\r\n")
+	if _, err := conexion.Write([]byte(solicitudConnect.String())); err != nil {
+		return "failed"
+	}
+
+	lector := bufio.NewReader(conexion)
+	lineaEstado, err := lector.ReadString('\n')
+	if err != nil {
+		return "failed"
+	}
+	if !EsRespuestaHTTP2xx(lineaEstado) {
+		if partes := strings.Fields(lineaEstado); len(partes) >= 2 && strings.HasPrefix(partes[1], "3") {
+			return "redirecting"
+		}
+		return "failed"
+	}
+	for {
+		linea, err := lector.ReadString('\n')
+		if err != nil {
+			return "failed"
+		}
+		if strings.TrimSpace(linea) == "" {
+			break
+		}
+	}
+
+	clienteTLS := tls.Client(conexionConLector{conexion, lector}, &tls.Config{ServerName: host})
+	err = clienteTLS.HandshakeContext(ctx)
+	if err == nil {
+		return "ok"
+	}
+
+	var errorNombre x509.HostnameError
+	var errorAutoridad x509.UnknownAuthorityError
+	var errorCertificado x509.CertificateInvalidError
+	var errorCabecera tls.RecordHeaderError
+	switch {
+	case errors.As(err, &errorNombre), errors.As(err, &errorAutoridad), errors.As(err, &errorCertificado):
+		return "captive"
+	case errors.As(err, &errorCabecera) && strings.HasPrefix(string(errorCabecera.RecordHeader[:]), "HTTP"):
+		return "redirecting"
+	}
+	return "failed"
+}
+
+// Guarda proxies/HTTP_captive.txt con los proxies HTTP cuyo tunel TLS no llega a -tls-target
+func (vp *VerificadorProxies) GuardarProxiesCautivos(resultados []Resultado) {
+	var lineas []string
+	for _, resultado := range resultados {
+		if resultado.TLS == "captive" || resultado.TLS == "redirecting" {
+			lineas = append(lineas, fmt.Sprintf("%s %s", resultado.Proxy, resultado.TLS))
+		}
+	}
+
+	dirFinal := "proxies"
+	os.MkdirAll(dirFinal, os.ModePerm)
+	rutaFinal := fmt.Sprintf("%s/HTTP_captive.txt", dirFinal)
+	contenido := fmt.Sprintf("# run_id %s\n", vp.IDEjecucion) + strings.Join(append(lineas, ""), "\n")
+	if err := os.WriteFile(rutaFinal, []byte(contenido), 0644); err != nil {
+		vp.Log("ERROR", fmt.Sprintf("No se pudieron guardar proxies cautivos: %v", err))
+		return
+	}
+	vp.Log("INFO", fmt.Sprintf("%d proxies http cautivos o redirigiendo guardados en %s", len(lineas), rutaFinal))
+}
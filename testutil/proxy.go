@@ -0,0 +1,279 @@
+// Paquete testutil: servidores SOCKS4/SOCKS5/HTTP CONNECT locales con fallos
+// configurables, al estilo de net/http/httptest, para probar el verificador
+// sin depender de proxies reales
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comportamiento de un servidor proxy simulado
+type Comportamiento struct {
+	Protocolo    string        // "socks4", "socks5" o "http"
+	RequiereAuth bool          // SOCKS4 exige identd, SOCKS5 usuario/clave y HTTP responde 407
+	Usuario      string        // credenciales aceptadas cuando RequiereAuth esta activo
+	Clave        string        // si estan vacias se acepta cualquier credencial
+	Retardo      time.Duration // espera antes de cada respuesta
+	Malformado   bool          // responde bytes que no siguen el protocolo
+	Rechazar     bool          // rechaza la conexion al objetivo
+	Fragmentar   bool          // envia las respuestas de a un byte para provocar lecturas cortas
+	Reiniciar    bool          // corta la conexion con RST en lugar de responder
+	LineaEstado  string        // linea de estado HTTP para respuestas exitosas (default "HTTP/1.1 200 Connection established")
+	Bind         bool          // SOCKS5 acepta el comando BIND; si no, responde "command not supported"
+}
+
+// Proxy local que escucha en 127.0.0.1 en un puerto libre
+type Servidor struct {
+	Direccion      string // ip:puerto en la que escucha
+	Comportamiento Comportamiento
+	listener       net.Listener
+	wg             sync.WaitGroup
+}
+
+// Inicia un servidor con el comportamiento dado; hay que cerrarlo con Cerrar
+func IniciarServidor(comportamiento Comportamiento) (*Servidor, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &Servidor{
+		Direccion:      listener.Addr().String(),
+		Comportamiento: comportamiento,
+		listener:       listener,
+	}
+	ss.wg.Add(1)
+	go ss.aceptar()
+	return ss, nil
+}
+
+// Como IniciarServidor pero entra en panico si no puede escuchar, igual que
+// httptest.NewServer; pensado para tests
+func NuevoServidor(comportamiento Comportamiento) *Servidor {
+	ss, err := IniciarServidor(comportamiento)
+	if err != nil {
+		panic(fmt.Sprintf("testutil: no se pudo escuchar en 127.0.0.1: %v", err))
+	}
+	return ss
+}
+
+// Cierra el servidor y espera a que terminen las conexiones abiertas
+func (ss *Servidor) Cerrar() {
+	ss.listener.Close()
+	ss.wg.Wait()
+}
+
+func (ss *Servidor) aceptar() {
+	defer ss.wg.Done()
+	for {
+		conexion, err := ss.listener.Accept()
+		if err != nil {
+			return
+		}
+		ss.wg.Add(1)
+		go func(c net.Conn) {
+			defer ss.wg.Done()
+			defer c.Close()
+			c.SetDeadline(time.Now().Add(30 * time.Second))
+			ss.atender(c)
+		}(conexion)
+	}
+}
+
+func (ss *Servidor) atender(conexion net.Conn) {
+	switch ss.Comportamiento.Protocolo {
+	case "socks4":
+		ss.atenderSOCKS4(conexion)
+	case "socks5":
+		ss.atenderSOCKS5(conexion)
+	case "http":
+		ss.atenderHTTP(conexion)
+	}
+}
+
+func (ss *Servidor) responder(conexion net.Conn, datos []byte) {
+	time.Sleep(ss.Comportamiento.Retardo)
+
+	if ss.Comportamiento.Reiniciar {
+		if tcp, ok := conexion.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		conexion.Close()
+		return
+	}
+
+	if ss.Comportamiento.Fragmentar {
+		for _, b := range datos {
+			if _, err := conexion.Write([]byte{b}); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return
+	}
+	conexion.Write(datos)
+}
+
+func (ss *Servidor) atenderSOCKS4(conexion net.Conn) {
+	lector := bufio.NewReader(conexion)
+
+	// VN, CD, DSTPORT, DSTIP y USERID terminado en 0x00
+	cabecera := make([]byte, 8)
+	if _, err := io.ReadFull(lector, cabecera); err != nil || cabecera[0] != 0x04 {
+		return
+	}
+	if _, err := lector.ReadBytes(0x00); err != nil {
+		return
+	}
+
+	switch {
+	case ss.Comportamiento.Malformado:
+		ss.responder(conexion, []byte("SSH-2.0-OpenSSH\r\n"))
+	case ss.Comportamiento.RequiereAuth:
+		ss.responder(conexion, []byte{0x00, 0x5D, 0, 0, 0, 0, 0, 0})
+	case ss.Comportamiento.Rechazar:
+		ss.responder(conexion, []byte{0x00, 0x5B, 0, 0, 0, 0, 0, 0})
+	default:
+		ss.responder(conexion, []byte{0x00, 0x5A, 0, 0, 0, 0, 0, 0})
+	}
+}
+
+func (ss *Servidor) atenderSOCKS5(conexion net.Conn) {
+	saludo := make([]byte, 2)
+	if _, err := io.ReadFull(conexion, saludo); err != nil || saludo[0] != 0x05 {
+		return
+	}
+	metodos := make([]byte, saludo[1])
+	if _, err := io.ReadFull(conexion, metodos); err != nil {
+		return
+	}
+
+	if ss.Comportamiento.Malformado {
+		ss.responder(conexion, []byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		return
+	}
+
+	if ss.Comportamiento.RequiereAuth {
+		if !strings.ContainsRune(string(metodos), 0x02) {
+			ss.responder(conexion, []byte{0x05, 0xFF})
+			return
+		}
+		ss.responder(conexion, []byte{0x05, 0x02})
+		if !ss.autenticarSOCKS5(conexion) {
+			return
+		}
+	} else {
+		ss.responder(conexion, []byte{0x05, 0x00})
+	}
+
+	// VER, CMD, RSV, ATYP y direccion de destino
+	solicitud := make([]byte, 4)
+	if _, err := io.ReadFull(conexion, solicitud); err != nil {
+		return
+	}
+	var largoDireccion int
+	switch solicitud[3] {
+	case 0x01:
+		largoDireccion = 4
+	case 0x04:
+		largoDireccion = 16
+	case 0x03:
+		largo := make([]byte, 1)
+		if _, err := io.ReadFull(conexion, largo); err != nil {
+			return
+		}
+		largoDireccion = int(largo[0])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conexion, make([]byte, largoDireccion+2)); err != nil {
+		return
+	}
+
+	codigo := byte(0x00)
+	if ss.Comportamiento.Rechazar {
+		codigo = 0x05
+	} else if solicitud[1] == 0x02 && !ss.Comportamiento.Bind {
+		codigo = 0x07
+	}
+	ss.responder(conexion, []byte{0x05, codigo, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+}
+
+// Subnegociacion usuario/clave (RFC 1929)
+func (ss *Servidor) autenticarSOCKS5(conexion net.Conn) bool {
+	leerCampo := func() (string, bool) {
+		largo := make([]byte, 1)
+		if _, err := io.ReadFull(conexion, largo); err != nil {
+			return "", false
+		}
+		campo := make([]byte, largo[0])
+		if _, err := io.ReadFull(conexion, campo); err != nil {
+			return "", false
+		}
+		return string(campo), true
+	}
+
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(conexion, version); err != nil || version[0] != 0x01 {
+		return false
+	}
+	usuario, ok := leerCampo()
+	if !ok {
+		return false
+	}
+	clave, ok := leerCampo()
+	if !ok {
+		return false
+	}
+
+	if ss.Comportamiento.Usuario != "" && (usuario != ss.Comportamiento.Usuario || clave != ss.Comportamiento.Clave) {
+		ss.responder(conexion, []byte{0x01, 0x01})
+		return false
+	}
+	ss.responder(conexion, []byte{0x01, 0x00})
+	return true
+}
+
+func (ss *Servidor) atenderHTTP(conexion net.Conn) {
+	lector := bufio.NewReader(conexion)
+	lineaSolicitud, err := lector.ReadString('\n')
+	if err != nil || !strings.HasPrefix(lineaSolicitud, "CONNECT ") {
+		return
+	}
+
+	autorizado := false
+	for {
+		linea, err := lector.ReadString('\n')
+		if err != nil {
+			return
+		}
+		linea = strings.TrimSpace(linea)
+		if linea == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(linea), "proxy-authorization:") {
+			autorizado = true
+		}
+	}
+
+	switch {
+	case ss.Comportamiento.Malformado:
+		ss.responder(conexion, []byte("220 smtp.simulado ESMTP\r\n"))
+	case ss.Comportamiento.RequiereAuth && !autorizado:
+		ss.responder(conexion, []byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"simulado\"\r\n\r\n"))
+	case ss.Comportamiento.Rechazar:
+		ss.responder(conexion, []byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+	default:
+		lineaEstado := ss.Comportamiento.LineaEstado
+		if lineaEstado == "" {
+			lineaEstado = "HTTP/1.1 200 Connection established"
+		}
+		ss.responder(conexion, []byte(lineaEstado+"\r\nProxy-Agent: simulado\r\n\r\n"))
+	}
+}
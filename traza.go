@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bytes de cada lectura o escritura que se muestran en la traza, como texto y
+// en hexadecimal; del resto solo se indica cuantos bytes se omitieron
+const maxTextoTraza = 200
+
+// Interpreta los valores de -trace ("proxy=1.2.3.4:1080,5.6.7.8:3128") y
+// devuelve las direcciones host:puerto a trazar
+func ParsearTrazas(valores []string) (map[string]bool, error) {
+	direcciones := make(map[string]bool)
+	for _, valor := range valores {
+		clave, lista, ok := strings.Cut(valor, "=")
+		if !ok || clave != "proxy" {
+			return nil, fmt.Errorf("-trace %q: se espera proxy=ip:puerto", valor)
+		}
+		for _, texto := range separarLista(lista) {
+			proxy, err := ParsearProxy(texto)
+			if err != nil {
+				return nil, fmt.Errorf("-trace: %v", err)
+			}
+			direcciones[proxy.Direccion()] = true
+		}
+	}
+	return direcciones, nil
+}
+
+// Registra en el log cada byte que pasa por la conexion, en hexadecimal y como
+// texto, para comparar el handshake con el de otro cliente
+type conexionTrazada struct {
+	net.Conn
+	vp        *VerificadorProxies
+	direccion string
+	inicio    time.Time
+	cerrada   sync.Once
+}
+
+func (vp *VerificadorProxies) trazar(conexion net.Conn, direccion string, inicio time.Time) net.Conn {
+	vp.Log("TRACE", fmt.Sprintf("%s conectado desde %s en %s", direccion, conexion.LocalAddr(), redondear(time.Since(inicio))))
+	return &conexionTrazada{Conn: conexion, vp: vp, direccion: direccion, inicio: inicio}
+}
+
+func (ct *conexionTrazada) registrar(sentido string, datos []byte, err error) {
+	transcurrido := redondear(time.Since(ct.inicio))
+	if len(datos) > 0 {
+		mostrados := datos
+		omitidos := ""
+		if len(mostrados) > maxTextoTraza {
+			mostrados = mostrados[:maxTextoTraza]
+			omitidos = fmt.Sprintf("\n... %d bytes mas", len(datos)-maxTextoTraza)
+		}
+		ct.vp.Log("TRACE", fmt.Sprintf("%s %s %d bytes (+%s) %s\n%s%s", ct.direccion, sentido, len(datos), transcurrido,
+			strconv.Quote(string(mostrados)), strings.TrimRight(hex.Dump(mostrados), "\n"), omitidos))
+	}
+	if err != nil {
+		ct.vp.Log("TRACE", fmt.Sprintf("%s %s error (+%s): %v", ct.direccion, sentido, transcurrido, err))
+	}
+}
+
+func (ct *conexionTrazada) Read(b []byte) (int, error) {
+	n, err := ct.Conn.Read(b)
+	ct.registrar("<-", b[:n], err)
+	return n, err
+}
+
+func (ct *conexionTrazada) Write(b []byte) (int, error) {
+	n, err := ct.Conn.Write(b)
+	ct.registrar("->", b[:n], err)
+	return n, err
+}
+
+func (ct *conexionTrazada) Close() error {
+	ct.cerrada.Do(func() {
+		ct.vp.Log("TRACE", fmt.Sprintf("%s cerrado (+%s)", ct.direccion, redondear(time.Since(ct.inicio))))
+	})
+	return ct.Conn.Close()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lilsheepyy/proxy-scrapper-checker/testutil"
+)
+
+func TestVerificarProxySimulado(t *testing.T) {
+	const timeout = 500 * time.Millisecond
+	casos := []struct {
+		nombre         string
+		comportamiento testutil.Comportamiento
+		credenciales   string // usuario:clave que se agregan a la entrada
+		estricto       bool
+		funcional      bool
+		autenticacion  string
+		servicio       string
+		clase          string
+	}{
+		{nombre: "socks4", comportamiento: testutil.Comportamiento{Protocolo: "socks4"}, funcional: true},
+		{nombre: "socks4 rechaza", comportamiento: testutil.Comportamiento{Protocolo: "socks4", Rechazar: true}, clase: ErrorDenegado},
+		{nombre: "socks4 identd", comportamiento: testutil.Comportamiento{Protocolo: "socks4", RequiereAuth: true}, clase: ErrorDenegado},
+		{nombre: "socks4 ssh", comportamiento: testutil.Comportamiento{Protocolo: "socks4", Malformado: true}, servicio: "ssh", clase: ErrorProtocolo},
+		{nombre: "socks4 lecturas cortas", comportamiento: testutil.Comportamiento{Protocolo: "socks4", Fragmentar: true}, funcional: true},
+
+		{nombre: "socks5", comportamiento: testutil.Comportamiento{Protocolo: "socks5"}, funcional: true},
+		{nombre: "socks5 rechaza", comportamiento: testutil.Comportamiento{Protocolo: "socks5", Rechazar: true}, clase: ErrorDenegado},
+		{nombre: "socks5 sin credenciales", comportamiento: testutil.Comportamiento{Protocolo: "socks5", RequiereAuth: true}, autenticacion: "required", clase: ErrorAuth},
+		{
+			nombre:         "socks5 con credenciales",
+			comportamiento: testutil.Comportamiento{Protocolo: "socks5", RequiereAuth: true, Usuario: "usuario", Clave: "clave"},
+			credenciales:   "usuario:clave",
+			funcional:      true,
+		},
+		{
+			nombre:         "socks5 credenciales incorrectas",
+			comportamiento: testutil.Comportamiento{Protocolo: "socks5", RequiereAuth: true, Usuario: "usuario", Clave: "clave"},
+			credenciales:   "usuario:otra",
+		},
+		{nombre: "socks5 http", comportamiento: testutil.Comportamiento{Protocolo: "socks5", Malformado: true}, servicio: "http", clase: ErrorProtocolo},
+		{nombre: "socks5 reset", comportamiento: testutil.Comportamiento{Protocolo: "socks5", Reiniciar: true}, clase: ErrorReset},
+		{nombre: "socks5 lento", comportamiento: testutil.Comportamiento{Protocolo: "socks5", Retardo: 2 * timeout}, clase: ErrorTimeout},
+
+		{nombre: "http", comportamiento: testutil.Comportamiento{Protocolo: "http"}, funcional: true},
+		{nombre: "http rechaza", comportamiento: testutil.Comportamiento{Protocolo: "http", Rechazar: true}, clase: ErrorDenegado},
+		{nombre: "http 407", comportamiento: testutil.Comportamiento{Protocolo: "http", RequiereAuth: true}, autenticacion: "required", clase: ErrorAuth},
+		{nombre: "http smtp", comportamiento: testutil.Comportamiento{Protocolo: "http", Malformado: true}, servicio: "smtp", clase: ErrorProtocolo},
+		{nombre: "http 1.0", comportamiento: testutil.Comportamiento{Protocolo: "http", LineaEstado: "HTTP/1.0 200 OK"}, funcional: true},
+		{nombre: "http 1.0 estricto", comportamiento: testutil.Comportamiento{Protocolo: "http", LineaEstado: "HTTP/1.0 200 OK"}, estricto: true, clase: ErrorDenegado},
+		{nombre: "http lecturas cortas", comportamiento: testutil.Comportamiento{Protocolo: "http", Fragmentar: true}, funcional: true},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			servidor := testutil.NuevoServidor(caso.comportamiento)
+			defer servidor.Cerrar()
+
+			vp, err := Nuevo(ConTimeout(timeout), ConEventos(func(string) {}, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer vp.FuncionCancelar()
+			vp.HTTPEstricto = caso.estricto
+
+			proxy := servidor.Direccion
+			if caso.credenciales != "" {
+				proxy = caso.credenciales + "@" + proxy
+			}
+			resultado := vp.VerificarProxy(context.Background(), caso.comportamiento.Protocolo, proxy)
+			if resultado.Funcional != caso.funcional {
+				t.Errorf("funcional = %t, se esperaba %t (%+v)", resultado.Funcional, caso.funcional, resultado)
+			}
+			if resultado.Autenticacion != caso.autenticacion && caso.autenticacion != "" {
+				t.Errorf("autenticacion = %q, se esperaba %q", resultado.Autenticacion, caso.autenticacion)
+			}
+			if resultado.Servicio != caso.servicio {
+				t.Errorf("servicio = %q, se esperaba %q", resultado.Servicio, caso.servicio)
+			}
+			if caso.clase != "" && resultado.ClaseError != caso.clase {
+				t.Errorf("clase de error = %q, se esperaba %q", resultado.ClaseError, caso.clase)
+			}
+			if resultado.Funcional && resultado.ClaseError != "" {
+				t.Errorf("resultado funcional con clase de error %q", resultado.ClaseError)
+			}
+		})
+	}
+}